@@ -162,6 +162,12 @@ const (
 	// alpha: v1.43.0
 	// beta: v1.46.0
 	ShootMaxTokenExpirationValidation featuregate.Feature = "ShootMaxTokenExpirationValidation"
+
+	// GardenerCustomMetrics enables the deployment of the gardener-custom-metrics component in seed clusters, which
+	// provides shoot kube-apiserver metrics via the custom metrics API for use in horizontal autoscaling.
+	// owner: @andrerun
+	// alpha: v1.46.0
+	GardenerCustomMetrics featuregate.Feature = "GardenerCustomMetrics"
 )
 
 var allFeatureGates = map[featuregate.Feature]featuregate.FeatureSpec{
@@ -185,6 +191,7 @@ var allFeatureGates = map[featuregate.Feature]featuregate.FeatureSpec{
 	ShootCARotation:                            {Default: false, PreRelease: featuregate.Alpha},
 	ShootMaxTokenExpirationOverwrite:           {Default: true, PreRelease: featuregate.Beta},
 	ShootMaxTokenExpirationValidation:          {Default: true, PreRelease: featuregate.Beta},
+	GardenerCustomMetrics:                      {Default: false, PreRelease: featuregate.Alpha},
 }
 
 // GetFeatures returns a feature gate map with the respective specifications. Non-existing feature gates are ignored.
@@ -133,6 +133,11 @@ func New(ctx context.Context, o *operation.Operation) (*Botanist, error) {
 		return nil, err
 	}
 	o.Shoot.Components.ControlPlane.EtcdCopyBackupsTask = b.DefaultEtcdCopyBackupsTask()
+	o.Shoot.Components.ControlPlane.GardenerCustomMetrics, err = b.DefaultGardenerCustomMetrics()
+	if err != nil {
+		return nil, err
+	}
+	o.Shoot.Components.ControlPlane.CustomMetricsHPA = b.DefaultCustomMetricsHPA()
 	o.Shoot.Components.ControlPlane.EtcdMain, err = b.DefaultEtcd(v1beta1constants.ETCDRoleMain, etcd.ClassImportant)
 	if err != nil {
 		return nil, err
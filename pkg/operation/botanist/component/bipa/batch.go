@@ -0,0 +1,86 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bipa
+
+import (
+	"context"
+	"fmt"
+
+	errorutils "github.com/gardener/gardener/pkg/utils/errors"
+
+	"github.com/hashicorp/go-multierror"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// BatchEntry describes one autoscaler in a batch reconciliation - the seed namespace of the respective shoot's
+// control plane, and the desired state to be applied to the autoscaler in it.
+type BatchEntry struct {
+	// Namespace is the name of the seed namespace holding the shoot's kube-apiserver.
+	Namespace string
+	// DesiredState is the desired state of the autoscaler in said namespace.
+	DesiredState DesiredStateParameters
+}
+
+// ReconcileBatch reconciles the kube-apiserver autoscalers of multiple shoots, running at most maxConcurrency
+// reconciliations at a time. A failing entry does not abort the batch - the remaining entries are still reconciled,
+// and the failures are aggregated into the returned error, each attributed to the namespace of the entry which caused
+// it. The eventRecorder parameter serves the same purpose as in New; a nil value disables event emission.
+func ReconcileBatch(
+	ctx context.Context,
+	seedClient client.Client,
+	eventRecorder record.EventRecorder,
+	entries []BatchEntry,
+	maxConcurrency int,
+) error {
+
+	if maxConcurrency < 1 {
+		return fmt.Errorf("the maximum reconciliation concurrency %d is below one", maxConcurrency)
+	}
+
+	var (
+		results   = make(chan error, 1)
+		semaphore = make(chan struct{}, maxConcurrency)
+		wg        wait.Group
+	)
+
+	for i := range entries {
+		entry := entries[i]
+		wg.StartWithContext(ctx, func(ctx context.Context) {
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			deployer := New(seedClient, entry.Namespace, eventRecorder, entry.DesiredState)
+			if _, err := deployer.Reconcile(ctx, entry.DesiredState); err != nil {
+				results <- fmt.Errorf("namespace '%s': %w", entry.Namespace, err)
+			}
+		})
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	errorList := &multierror.Error{
+		ErrorFormat: errorutils.NewErrorFormatFuncWithPrefix("Could not reconcile the kube-apiserver autoscalers of all shoots"),
+	}
+	for err := range results {
+		errorList = multierror.Append(errorList, err)
+	}
+
+	return errorList.ErrorOrNil()
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bipa_test
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gardener/gardener/pkg/client/kubernetes"
+	. "github.com/gardener/gardener/pkg/operation/botanist/component/bipa"
+	kutil "github.com/gardener/gardener/pkg/utils/kubernetes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	vpaautoscalingv1 "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("ReconcileBatch", func() {
+	var (
+		ctx        context.Context
+		seedClient client.Client
+	)
+
+	BeforeEach(func() {
+		ctx = context.TODO()
+		seedClient = fakeclient.NewClientBuilder().WithScheme(kubernetes.SeedScheme).Build()
+	})
+
+	enabledState := func() DesiredStateParameters {
+		return DesiredStateParameters{
+			IsEnabled:       true,
+			MinReplicaCount: 1,
+			MaxReplicaCount: 4,
+		}
+	}
+
+	It("should reconcile the autoscalers of all specified namespaces", func() {
+		var entries []BatchEntry
+		for i := 0; i < 5; i++ {
+			entries = append(entries, BatchEntry{
+				Namespace:    fmt.Sprintf("shoot--foo--bar%d", i),
+				DesiredState: enabledState(),
+			})
+		}
+
+		Expect(ReconcileBatch(ctx, seedClient, nil, entries, 2)).To(Succeed())
+
+		for _, entry := range entries {
+			Expect(seedClient.Get(ctx, kutil.Key(entry.Namespace, "kube-apiserver-bipa"),
+				&autoscalingv2beta2.HorizontalPodAutoscaler{})).To(Succeed())
+			Expect(seedClient.Get(ctx, kutil.Key(entry.Namespace, "kube-apiserver-bipa"),
+				&vpaautoscalingv1.VerticalPodAutoscaler{})).To(Succeed())
+		}
+	})
+
+	It("should reconcile the remaining entries if one fails, and attribute the failure to its namespace", func() {
+		entries := []BatchEntry{
+			{Namespace: "shoot--foo--good1", DesiredState: enabledState()},
+			{Namespace: "shoot--foo--bad", DesiredState: DesiredStateParameters{
+				IsEnabled:       true,
+				MinReplicaCount: 0, // Invalid - the lower bound of the scaling range must be at least one
+				MaxReplicaCount: 4,
+			}},
+			{Namespace: "shoot--foo--good2", DesiredState: enabledState()},
+		}
+
+		err := ReconcileBatch(ctx, seedClient, nil, entries, 2)
+
+		Expect(err).To(MatchError(And(
+			ContainSubstring("namespace 'shoot--foo--bad'"),
+			ContainSubstring("below one"),
+		)))
+		Expect(seedClient.Get(ctx, kutil.Key("shoot--foo--good1", "kube-apiserver-bipa"),
+			&autoscalingv2beta2.HorizontalPodAutoscaler{})).To(Succeed())
+		Expect(seedClient.Get(ctx, kutil.Key("shoot--foo--good2", "kube-apiserver-bipa"),
+			&autoscalingv2beta2.HorizontalPodAutoscaler{})).To(Succeed())
+	})
+
+	It("should refuse a concurrency bound below one", func() {
+		Expect(ReconcileBatch(ctx, seedClient, nil, nil, 0)).
+			To(MatchError(ContainSubstring("concurrency 0 is below one")))
+	})
+})
@@ -0,0 +1,724 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bipa implements the built-in pod autoscaler ("bipa") for the shoot kube-apiserver: an HPA which scales the
+// kube-apiserver deployment based on the custom request-rate metric provided by gardener-custom-metrics, combined
+// with a VPA which adjusts the container resource requests within each replica.
+package bipa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	v1beta1constants "github.com/gardener/gardener/pkg/apis/core/v1beta1/constants"
+	resourcesv1alpha1 "github.com/gardener/gardener/pkg/apis/resources/v1alpha1"
+	"github.com/gardener/gardener/pkg/client/kubernetes"
+	"github.com/gardener/gardener/pkg/controllerutils"
+	"github.com/gardener/gardener/pkg/operation/botanist/component"
+	"github.com/gardener/gardener/pkg/operation/botanist/component/gardenercustommetrics"
+	gutil "github.com/gardener/gardener/pkg/utils/gardener"
+	kutil "github.com/gardener/gardener/pkg/utils/kubernetes"
+	"github.com/gardener/gardener/pkg/utils/managedresources"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	vpaautoscalingv1 "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// autoscalerName is the name of the HPA and VPA objects deployed by this component.
+	autoscalerName = v1beta1constants.DeploymentNameKubeAPIServer + "-bipa"
+	// managedResourceTargetName is the name of the ManagedResource which deploys bipa's shoot-side app resources.
+	managedResourceTargetName = "shoot-core-bipa"
+	// shootAccessSecretName is the name of the shoot access secret through which metrics are scraped from the shoot
+	// kube-apiserver.
+	shootAccessSecretName = "bipa"
+	// defaultShootClusterRoleName is the default name of the ClusterRole deployed to the shoot, which permits
+	// scraping the kube-apiserver's metrics endpoint.
+	defaultShootClusterRoleName = "gardener.cloud:target:bipa"
+
+	// MetricName is the name of the custom pods metric on which the HPA scales the kube-apiserver. It is the
+	// request-rate metric provided by gardener-custom-metrics.
+	MetricName = gardenercustommetrics.APIServerRequestRateMetricName
+	// hpaTargetAverageValue is the per-replica request rate above which the HPA adds replicas.
+	hpaTargetAverageValue = "400"
+
+	// shootAccessSecretLabelKey is the key of the label on the shoot access secret by which gardener-custom-metrics
+	// discovers its scrape targets.
+	shootAccessSecretLabelKey = "name"
+	// defaultShootAccessSecretLabelValue is the default value of said label.
+	defaultShootAccessSecretLabelValue = "shoot-access-gardener-custom-metrics"
+)
+
+// Interface contains functions for a bipa deployer.
+type Interface interface {
+	component.DeployWaiter
+	// Reconcile brings the autoscaler in the seed to the specified desired state, deploying, adjusting, or removing
+	// k8s objects as necessary. The returned ReconcileResult reports which of the autoscaler's server objects were
+	// actually changed in the process; it is valid even if the reconciliation failed partway.
+	Reconcile(ctx context.Context, desiredState DesiredStateParameters) (ReconcileResult, error)
+	// GetShootAccessSecretName returns the name of the seed-side shoot access secret through which
+	// gardener-custom-metrics scrapes the shoot kube-apiserver's metrics endpoint.
+	GetShootAccessSecretName() string
+	// CheckMetricsAvailability inspects the server-side status of the autoscaler's HPA and returns a
+	// *MetricsUnavailableError if the HPA reports that it cannot retrieve the custom request-rate metric on which it
+	// scales — e.g. because gardener-custom-metrics has not yet populated it. A nil result means no such problem was
+	// observed.
+	CheckMetricsAvailability(ctx context.Context) error
+	// GetDesiredState reads the autoscaler's server-side HPA and VPA and reconstructs the desired state they reflect,
+	// so that callers can compare it against intended state (drift detection). Only the fields recorded in those
+	// objects are reconstructed: enablement, the horizontal scaling range, scale-up rules, and additional metrics. If
+	// the autoscaler's objects do not exist on the server, the returned state has IsEnabled set to false.
+	GetDesiredState(ctx context.Context) (*DesiredStateParameters, error)
+}
+
+// ErrInvalidReplicaRange indicates that the horizontal scaling range passed to Reconcile is not valid - its lower
+// bound is below one, or its upper bound is below its lower bound. The errors which wrap it carry the descriptive
+// context; callers branch on the condition via errors.Is.
+var ErrInvalidReplicaRange = errors.New("the horizontal scaling range is invalid")
+
+// MetricsUnavailableError indicates that the autoscaler's HPA cannot retrieve the custom request-rate metric on which
+// it scales, i.e. the metrics pipeline between gardener-custom-metrics and the HPA is broken or not yet initialized.
+type MetricsUnavailableError struct {
+	// Message is the diagnostic message reported by the HPA.
+	Message string
+}
+
+// Error implements the error interface.
+func (e *MetricsUnavailableError) Error() string {
+	return fmt.Sprintf("the kube-apiserver autoscaler cannot retrieve the custom metric '%s' on which it scales: %s",
+		MetricName, e.Message)
+}
+
+// ReconcileResult reports the server-side effect of a Reconcile call on the autoscaler's individual objects. It
+// allows callers to tell no-op reconciliations apart from ones which actually changed the server, e.g. to avoid
+// logging a state change which did not occur.
+type ReconcileResult struct {
+	// HpaOperation specifies whether the reconciliation created, updated, or left unchanged the autoscaler's HPA.
+	HpaOperation controllerutil.OperationResult
+	// VpaOperation specifies whether the reconciliation created, updated, or left unchanged the autoscaler's VPA.
+	VpaOperation controllerutil.OperationResult
+}
+
+// DesiredStateParameters describes the desired state of the autoscaler, to be applied by Reconcile.
+type DesiredStateParameters struct {
+	// IsEnabled specifies whether the autoscaler should exist. If false, Reconcile removes all of bipa's objects.
+	IsEnabled bool
+	// MinReplicaCount is the lower bound of the horizontal scaling range of the kube-apiserver. It also serves as the
+	// basis for the VPA's eviction threshold - see vpaMinReplicaCount.
+	MinReplicaCount int32
+	// MaxReplicaCount is the upper bound of the horizontal scaling range of the kube-apiserver.
+	MaxReplicaCount int32
+	// ShootAccessSecretLabelValue, if set, overrides the value of the 'name' label on the shoot access secret, by
+	// which gardener-custom-metrics discovers its scrape targets. Deployments running multiple autoscaler variants
+	// side by side use distinct values to avoid cross-scraping. If empty,
+	// defaultShootAccessSecretLabelValue applies.
+	ShootAccessSecretLabelValue string
+	// ShootClusterRoleName, if set, overrides the name of the ClusterRole and ClusterRoleBinding deployed to the
+	// shoot. Since these objects are cluster-scoped, autoscaler variants running against the same shoot need
+	// distinct names to coexist. If empty, defaultShootClusterRoleName applies.
+	ShootClusterRoleName string
+	// DeployShootResources specifies whether the shoot-side app resources (the scraping RBAC and the shoot access
+	// secret feeding it) are deployed. If nil, they are. Setups in which gardener-custom-metrics scrapes via a
+	// different auth mechanism disable them, to avoid deploying unnecessary objects into the shoot; previously
+	// created resources are removed.
+	DeployShootResources *bool
+	// ScaleUpRules, if set, overrides the HPA's scale-up behavior, e.g. to scale out faster for bursty
+	// kube-apiservers. If nil, the Kubernetes default scale-up behavior applies.
+	ScaleUpRules *autoscalingv2beta2.HPAScalingRules
+	// AdditionalMetrics are metrics on which the HPA scales, in addition to the built-in custom request-rate metric -
+	// e.g. a resource CPU metric as a fallback while the custom metrics pipeline initializes. The metrics observed by
+	// the individual entries must not overlap each other, or the built-in metric. Empty by default.
+	AdditionalMetrics []autoscalingv2beta2.MetricSpec
+	// SetApiserverAsOwner specifies whether the HPA and VPA carry an owner reference to the kube-apiserver
+	// Deployment, so that they are garbage-collected if the Deployment is deleted out of band. Disabled by default,
+	// which leaves removal entirely to Destroy.
+	SetApiserverAsOwner bool
+}
+
+// New creates a new bipa deployer for the shoot control plane in the specified seed namespace. The desiredState
+// parameter is the state which the Deploy function applies. The eventRecorder parameter is used to emit k8s Events
+// about notable reconciliation outcomes onto the autoscaler's HPA; a nil value disables event emission.
+func New(seedClient client.Client, namespace string, eventRecorder record.EventRecorder, desiredState DesiredStateParameters) Interface {
+	return &bipa{
+		seedClient:    seedClient,
+		namespace:     namespace,
+		eventRecorder: eventRecorder,
+		desiredState:  desiredState,
+	}
+}
+
+type bipa struct {
+	seedClient    client.Client
+	namespace     string
+	eventRecorder record.EventRecorder
+	desiredState  DesiredStateParameters
+}
+
+// recordEvent emits a k8s Event onto the autoscaler's HPA, if an event recorder is configured.
+func (b *bipa) recordEvent(eventType, reason, messageFormat string, args ...interface{}) {
+	if b.eventRecorder == nil {
+		return
+	}
+	b.eventRecorder.Eventf(b.emptyHpa(), eventType, reason, messageFormat, args...)
+}
+
+// Deploy implements component.Deployer.
+func (b *bipa) Deploy(ctx context.Context) error {
+	_, err := b.Reconcile(ctx, b.desiredState)
+	return err
+}
+
+// Reconcile brings the autoscaler in the seed to the specified desired state.
+func (b *bipa) Reconcile(ctx context.Context, desiredState DesiredStateParameters) (ReconcileResult, error) {
+	result := ReconcileResult{
+		HpaOperation: controllerutil.OperationResultNone,
+		VpaOperation: controllerutil.OperationResultNone,
+	}
+
+	if err := validateNamespace(b.namespace); err != nil {
+		return result, fmt.Errorf("refusing to reconcile the kube-apiserver autoscaler: %w", err)
+	}
+
+	if !desiredState.IsEnabled {
+		return result, b.Destroy(ctx)
+	}
+
+	if err := validateReplicaRange(desiredState.MinReplicaCount, desiredState.MaxReplicaCount); err != nil {
+		return result, fmt.Errorf("refusing to reconcile the kube-apiserver autoscaler in namespace '%s': %w", b.namespace, err)
+	}
+
+	if err := validateAdditionalMetrics(desiredState.AdditionalMetrics); err != nil {
+		return result, fmt.Errorf("refusing to reconcile the kube-apiserver autoscaler in namespace '%s': %w", b.namespace, err)
+	}
+
+	// On steady-state cycles the server-side objects usually already match the desired state. Detecting that via a
+	// single read pass and skipping the patch calls keeps the recurring reconciliations from loading the apiserver on
+	// large seeds. The owner reference mode is not reconstructable from the read state, so it disables the short
+	// circuit. A read failure here is not acted upon - the full reconciliation below surfaces persistent problems.
+	if !desiredState.SetApiserverAsOwner {
+		if observedState, err := b.GetDesiredState(ctx); err == nil && isDesiredStateObserved(observedState, &desiredState) {
+			// Only the shoot access secret's token freshness still needs attention when nothing else changed. The
+			// metrics availability check is read-only and its warning signal remains valuable on steady-state cycles.
+			b.warnIfMetricsUnavailable(ctx)
+			return result, b.reconcileShootResources(ctx, desiredState)
+		}
+	}
+
+	var ownerReference *metav1.OwnerReference
+	if desiredState.SetApiserverAsOwner {
+		var err error
+		if ownerReference, err = b.apiserverOwnerReference(ctx); err != nil {
+			return result, fmt.Errorf("failed to determine the owner reference for the kube-apiserver autoscaler in namespace '%s': %w", b.namespace, err)
+		}
+	}
+
+	hpaOperation, replicaRangeChanged, err := b.reconcileHpa(ctx, desiredState, ownerReference)
+	if err != nil {
+		return result, fmt.Errorf("failed to reconcile the HPA of the kube-apiserver autoscaler in namespace '%s': %w", b.namespace, err)
+	}
+	result.HpaOperation = hpaOperation
+	if replicaRangeChanged {
+		b.recordEvent(corev1.EventTypeNormal, "ReplicaRangeChanged",
+			"Changed the horizontal scaling range of the kube-apiserver to [%d, %d]",
+			desiredState.MinReplicaCount, desiredState.MaxReplicaCount)
+	}
+
+	b.warnIfMetricsUnavailable(ctx)
+
+	vpaOperation, err := b.reconcileVpa(ctx, desiredState.MinReplicaCount, desiredState.MaxReplicaCount, ownerReference)
+	if err != nil {
+		return result, fmt.Errorf("failed to reconcile the VPA of the kube-apiserver autoscaler in namespace '%s': %w", b.namespace, err)
+	}
+	result.VpaOperation = vpaOperation
+
+	if err := b.reconcileShootResources(ctx, desiredState); err != nil {
+		return result, err
+	}
+
+	b.recordEvent(corev1.EventTypeNormal, "Reconciled", "Successfully reconciled the kube-apiserver autoscaler")
+	return result, nil
+}
+
+// warnIfMetricsUnavailable emits a user-visible warning event if the autoscaler's HPA reports that it cannot retrieve
+// the custom metric on which it scales. A broken metrics pipeline does not fail reconciliation, but it is worth that
+// signal.
+func (b *bipa) warnIfMetricsUnavailable(ctx context.Context) {
+	metricsUnavailableError := &MetricsUnavailableError{}
+	if err := b.CheckMetricsAvailability(ctx); errors.As(err, &metricsUnavailableError) {
+		b.recordEvent(corev1.EventTypeWarning, "MetricsUnavailable", "%s", metricsUnavailableError.Error())
+	}
+}
+
+// reconcileShootResources brings the shoot-side app resources (the scraping RBAC and the shoot access secret feeding
+// it) to their configured state - deployed and fresh, or absent. It runs on every reconciliation cycle, including the
+// ones which leave the autoscaler's HPA and VPA untouched, because the shoot access secret's token requires periodic
+// renewal regardless of autoscaler state changes.
+func (b *bipa) reconcileShootResources(ctx context.Context, desiredState DesiredStateParameters) error {
+	if !pointer.BoolDeref(desiredState.DeployShootResources, true) {
+		if err := kutil.DeleteObjects(
+			ctx,
+			b.seedClient,
+			b.emptyManagedResource(),
+			b.emptyManagedResourceSecret(),
+			b.newShootAccessSecret().Secret,
+		); err != nil {
+			return fmt.Errorf("failed to remove the shoot resources of the kube-apiserver autoscaler in namespace '%s': %w", b.namespace, err)
+		}
+		return nil
+	}
+
+	shootAccessSecret := b.newShootAccessSecret()
+	if err := shootAccessSecret.Reconcile(ctx, b.seedClient); err != nil {
+		return fmt.Errorf("failed to reconcile the shoot access secret of the kube-apiserver autoscaler in namespace '%s': %w", b.namespace, err)
+	}
+
+	if err := b.labelShootAccessSecret(ctx, shootAccessSecret.Secret, desiredState.ShootAccessSecretLabelValue); err != nil {
+		return fmt.Errorf("failed to label the shoot access secret of the kube-apiserver autoscaler in namespace '%s': %w", b.namespace, err)
+	}
+
+	clusterRoleName := desiredState.ShootClusterRoleName
+	if clusterRoleName == "" {
+		clusterRoleName = defaultShootClusterRoleName
+	}
+
+	data, err := b.computeShootResourcesData(shootAccessSecret.ServiceAccountName, clusterRoleName)
+	if err != nil {
+		return fmt.Errorf("failed to compute the shoot resources of the kube-apiserver autoscaler in namespace '%s': %w", b.namespace, err)
+	}
+
+	if err := managedresources.CreateForShoot(ctx, b.seedClient, b.namespace, managedResourceTargetName, false, data); err != nil {
+		return fmt.Errorf("failed to deploy the shoot resources of the kube-apiserver autoscaler in namespace '%s': %w", b.namespace, err)
+	}
+
+	return nil
+}
+
+// Destroy implements component.Deployer.
+func (b *bipa) Destroy(ctx context.Context) error {
+	return kutil.DeleteObjects(
+		ctx,
+		b.seedClient,
+		b.emptyManagedResource(),
+		b.emptyManagedResourceSecret(),
+		b.emptyHpa(),
+		b.emptyVpa(),
+		b.newShootAccessSecret().Secret,
+	)
+}
+
+// Wait implements component.Waiter.
+func (b *bipa) Wait(_ context.Context) error { return nil }
+
+// WaitCleanup implements component.Waiter.
+func (b *bipa) WaitCleanup(_ context.Context) error { return nil }
+
+// apiserverOwnerReference returns an owner reference pointing to the kube-apiserver Deployment in the component's
+// namespace.
+func (b *bipa) apiserverOwnerReference(ctx context.Context) (*metav1.OwnerReference, error) {
+	deployment := &appsv1.Deployment{}
+	if err := b.seedClient.Get(ctx, kutil.Key(b.namespace, v1beta1constants.DeploymentNameKubeAPIServer), deployment); err != nil {
+		return nil, err
+	}
+
+	return &metav1.OwnerReference{
+		APIVersion: appsv1.SchemeGroupVersion.String(),
+		Kind:       "Deployment",
+		Name:       deployment.Name,
+		UID:        deployment.UID,
+	}, nil
+}
+
+// reconcileHpa brings the autoscaler's HPA to the specified desired state. The returned operation value reports
+// whether the HPA was created, updated, or left unchanged in the process; the returned replicaRangeChanged value
+// reports whether an existing HPA's scaling range was changed.
+func (b *bipa) reconcileHpa(
+	ctx context.Context, desiredState DesiredStateParameters, ownerReference *metav1.OwnerReference,
+) (controllerutil.OperationResult, bool, error) {
+
+	minReplicaCount := desiredState.MinReplicaCount
+	targetAverageValue := resource.MustParse(hpaTargetAverageValue)
+
+	var behavior *autoscalingv2beta2.HorizontalPodAutoscalerBehavior
+	if desiredState.ScaleUpRules != nil {
+		behavior = &autoscalingv2beta2.HorizontalPodAutoscalerBehavior{ScaleUp: desiredState.ScaleUpRules}
+	}
+
+	var (
+		hpaExisted, replicaRangeChanged bool
+		serverState                     *autoscalingv2beta2.HorizontalPodAutoscaler
+	)
+
+	hpa := b.emptyHpa()
+	operation, err := controllerutils.GetAndCreateOrMergePatch(ctx, b.seedClient, hpa, func() error {
+		// At this point the object holds the server-side state, if any.
+		serverState = hpa.DeepCopy()
+		if hpaExisted = hpa.Spec.MaxReplicas != 0; hpaExisted {
+			replicaRangeChanged = pointer.Int32Deref(hpa.Spec.MinReplicas, 0) != minReplicaCount ||
+				hpa.Spec.MaxReplicas != desiredState.MaxReplicaCount
+		}
+
+		hpa.Labels = getLabels()
+		if ownerReference != nil {
+			hpa.OwnerReferences = []metav1.OwnerReference{*ownerReference}
+		}
+		hpa.Spec = autoscalingv2beta2.HorizontalPodAutoscalerSpec{
+			MinReplicas: &minReplicaCount,
+			MaxReplicas: desiredState.MaxReplicaCount,
+			ScaleTargetRef: autoscalingv2beta2.CrossVersionObjectReference{
+				APIVersion: appsv1.SchemeGroupVersion.String(),
+				Kind:       "Deployment",
+				Name:       v1beta1constants.DeploymentNameKubeAPIServer,
+			},
+			Metrics: append([]autoscalingv2beta2.MetricSpec{{
+				Type: autoscalingv2beta2.PodsMetricSourceType,
+				Pods: &autoscalingv2beta2.PodsMetricSource{
+					Metric: autoscalingv2beta2.MetricIdentifier{
+						Name: MetricName,
+					},
+					Target: autoscalingv2beta2.MetricTarget{
+						Type:         autoscalingv2beta2.AverageValueMetricType,
+						AverageValue: &targetAverageValue,
+					},
+				},
+			}}, desiredState.AdditionalMetrics...),
+			Behavior: behavior,
+		}
+		return nil
+	})
+	if err != nil {
+		return controllerutil.OperationResultNone, false, err
+	}
+
+	// GetAndCreateOrMergePatch sends a patch request even if the mutation was a no-op, and reports it as an update.
+	// Compare against the pre-mutation server state to tell the two cases apart.
+	if operation == controllerutil.OperationResultUpdated && isHpaUnchanged(serverState, hpa) {
+		operation = controllerutil.OperationResultNone
+	}
+
+	return operation, replicaRangeChanged, nil
+}
+
+// isHpaUnchanged reports whether the fields managed by reconcileHpa are semantically equal between the two given
+// HPAs.
+func isHpaUnchanged(oldHpa, newHpa *autoscalingv2beta2.HorizontalPodAutoscaler) bool {
+	return apiequality.Semantic.DeepEqual(oldHpa.Spec, newHpa.Spec) &&
+		apiequality.Semantic.DeepEqual(oldHpa.Labels, newHpa.Labels) &&
+		apiequality.Semantic.DeepEqual(oldHpa.OwnerReferences, newHpa.OwnerReferences)
+}
+
+// reconcileVpa brings the autoscaler's VPA to the specified desired state. The returned operation value reports
+// whether the VPA was created, updated, or left unchanged in the process.
+func (b *bipa) reconcileVpa(
+	ctx context.Context, minReplicaCount, maxReplicaCount int32, ownerReference *metav1.OwnerReference,
+) (controllerutil.OperationResult, error) {
+
+	var (
+		updateMode       = vpaautoscalingv1.UpdateModeAuto
+		controlledValues = vpaautoscalingv1.ContainerControlledValuesRequestsOnly
+		vpaMinReplicas   = vpaMinReplicaCount(minReplicaCount, maxReplicaCount)
+		serverState      *vpaautoscalingv1.VerticalPodAutoscaler
+	)
+
+	vpa := b.emptyVpa()
+	operation, err := controllerutils.GetAndCreateOrMergePatch(ctx, b.seedClient, vpa, func() error {
+		serverState = vpa.DeepCopy()
+		vpa.Labels = getLabels()
+		if ownerReference != nil {
+			vpa.OwnerReferences = []metav1.OwnerReference{*ownerReference}
+		}
+		vpa.Spec = vpaautoscalingv1.VerticalPodAutoscalerSpec{
+			TargetRef: &autoscalingv1.CrossVersionObjectReference{
+				APIVersion: appsv1.SchemeGroupVersion.String(),
+				Kind:       "Deployment",
+				Name:       v1beta1constants.DeploymentNameKubeAPIServer,
+			},
+			UpdatePolicy: &vpaautoscalingv1.PodUpdatePolicy{
+				UpdateMode:  &updateMode,
+				MinReplicas: &vpaMinReplicas,
+			},
+			ResourcePolicy: &vpaautoscalingv1.PodResourcePolicy{
+				ContainerPolicies: []vpaautoscalingv1.ContainerResourcePolicy{{
+					ContainerName:    vpaautoscalingv1.DefaultContainerResourcePolicy,
+					ControlledValues: &controlledValues,
+				}},
+			},
+		}
+		return nil
+	})
+	if err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+
+	if operation == controllerutil.OperationResultUpdated && isVpaUnchanged(serverState, vpa) {
+		operation = controllerutil.OperationResultNone
+	}
+
+	return operation, nil
+}
+
+// isVpaUnchanged reports whether the fields managed by reconcileVpa are semantically equal between the two given
+// VPAs.
+func isVpaUnchanged(oldVpa, newVpa *vpaautoscalingv1.VerticalPodAutoscaler) bool {
+	return apiequality.Semantic.DeepEqual(oldVpa.Spec, newVpa.Spec) &&
+		apiequality.Semantic.DeepEqual(oldVpa.Labels, newVpa.Labels) &&
+		apiequality.Semantic.DeepEqual(oldVpa.OwnerReferences, newVpa.OwnerReferences)
+}
+
+// isDesiredStateObserved reports whether the desired state matches the state reconstructed from the server-side
+// objects by GetDesiredState, over the fields which that reconstruction covers.
+func isDesiredStateObserved(observedState, desiredState *DesiredStateParameters) bool {
+	return observedState.IsEnabled == desiredState.IsEnabled &&
+		observedState.MinReplicaCount == desiredState.MinReplicaCount &&
+		observedState.MaxReplicaCount == desiredState.MaxReplicaCount &&
+		apiequality.Semantic.DeepEqual(observedState.ScaleUpRules, desiredState.ScaleUpRules) &&
+		apiequality.Semantic.DeepEqual(observedState.AdditionalMetrics, desiredState.AdditionalMetrics)
+}
+
+// metricIdentifier returns a string which identifies the metric observed by the given metric spec, for the purpose of
+// detecting duplicates.
+func metricIdentifier(metric *autoscalingv2beta2.MetricSpec) string {
+	switch metric.Type {
+	case autoscalingv2beta2.PodsMetricSourceType:
+		if metric.Pods != nil {
+			return "pods/" + metric.Pods.Metric.Name
+		}
+	case autoscalingv2beta2.ResourceMetricSourceType:
+		if metric.Resource != nil {
+			return "resource/" + string(metric.Resource.Name)
+		}
+	case autoscalingv2beta2.ContainerResourceMetricSourceType:
+		if metric.ContainerResource != nil {
+			return "containerResource/" + string(metric.ContainerResource.Name) + "/" + metric.ContainerResource.Container
+		}
+	case autoscalingv2beta2.ObjectMetricSourceType:
+		if metric.Object != nil {
+			return "object/" + metric.Object.Metric.Name
+		}
+	case autoscalingv2beta2.ExternalMetricSourceType:
+		if metric.External != nil {
+			return "external/" + metric.External.Metric.Name
+		}
+	}
+	return string(metric.Type)
+}
+
+// validateNamespace verifies that the specified value is usable as the name of the seed namespace holding the
+// autoscaler's objects. Without this check, a misconfigured (e.g. empty) namespace name would surface only as an
+// obscure apiserver rejection of the individual object operations.
+func validateNamespace(namespace string) error {
+	if namespace == "" {
+		return errors.New("the name of the autoscaler's namespace is empty")
+	}
+	if messages := validation.IsDNS1123Label(namespace); len(messages) > 0 {
+		return fmt.Errorf("the name '%s' of the autoscaler's namespace is not a valid Kubernetes namespace name: %s",
+			namespace, strings.Join(messages, ", "))
+	}
+	return nil
+}
+
+// validateReplicaRange verifies that the specified horizontal scaling range is usable as HPA replica bounds. Errors
+// returned by it wrap ErrInvalidReplicaRange.
+func validateReplicaRange(minReplicaCount, maxReplicaCount int32) error {
+	if minReplicaCount < 1 {
+		return fmt.Errorf("the lower bound %d of the horizontal scaling range is below one: %w",
+			minReplicaCount, ErrInvalidReplicaRange)
+	}
+	if maxReplicaCount < minReplicaCount {
+		return fmt.Errorf("the upper bound %d of the horizontal scaling range is below the lower bound %d: %w",
+			maxReplicaCount, minReplicaCount, ErrInvalidReplicaRange)
+	}
+	return nil
+}
+
+// validateAdditionalMetrics verifies that the specified additional HPA metrics do not duplicate each other, or the
+// built-in custom request-rate metric.
+func validateAdditionalMetrics(metrics []autoscalingv2beta2.MetricSpec) error {
+	seen := map[string]struct{}{
+		"pods/" + MetricName: {},
+	}
+	for i := range metrics {
+		identifier := metricIdentifier(&metrics[i])
+		if _, isDuplicate := seen[identifier]; isDuplicate {
+			return fmt.Errorf("the additional HPA metric '%s' duplicates another metric on the HPA", identifier)
+		}
+		seen[identifier] = struct{}{}
+	}
+	return nil
+}
+
+// vpaMinReplicaCount returns the value for the VPA's UpdatePolicy.MinReplicas, derived from the horizontal scaling
+// range configured on the HPA. The VPA updater only evicts a pod if at least MinReplicas replicas are alive. If that
+// threshold was allowed to reach the upper bound of the scaling range (e.g. when MinReplicaCount equals
+// MaxReplicaCount), the VPA could permanently refuse to apply its recommendation. To avoid that, the threshold is kept
+// strictly below the upper bound of the range, but never below one.
+func vpaMinReplicaCount(minReplicaCount, maxReplicaCount int32) int32 {
+	result := minReplicaCount
+	if result >= maxReplicaCount {
+		result = maxReplicaCount - 1
+	}
+	if result < 1 {
+		result = 1
+	}
+	return result
+}
+
+func getLabels() map[string]string {
+	return map[string]string{
+		v1beta1constants.LabelApp:  v1beta1constants.LabelKubernetes,
+		v1beta1constants.LabelRole: "bipa",
+	}
+}
+
+func (b *bipa) emptyHpa() *autoscalingv2beta2.HorizontalPodAutoscaler {
+	return &autoscalingv2beta2.HorizontalPodAutoscaler{ObjectMeta: metav1.ObjectMeta{Name: autoscalerName, Namespace: b.namespace}}
+}
+
+func (b *bipa) emptyVpa() *vpaautoscalingv1.VerticalPodAutoscaler {
+	return &vpaautoscalingv1.VerticalPodAutoscaler{ObjectMeta: metav1.ObjectMeta{Name: autoscalerName, Namespace: b.namespace}}
+}
+
+func (b *bipa) newShootAccessSecret() *gutil.ShootAccessSecret {
+	return gutil.NewShootAccessSecret(shootAccessSecretName, b.namespace)
+}
+
+// GetShootAccessSecretName implements Interface.
+func (b *bipa) GetShootAccessSecretName() string {
+	return b.newShootAccessSecret().Secret.Name
+}
+
+// GetDesiredState implements Interface.
+func (b *bipa) GetDesiredState(ctx context.Context) (*DesiredStateParameters, error) {
+	hpa := b.emptyHpa()
+	if err := b.seedClient.Get(ctx, client.ObjectKeyFromObject(hpa), hpa); err != nil {
+		if apierrors.IsNotFound(err) {
+			return &DesiredStateParameters{IsEnabled: false}, nil
+		}
+		return nil, fmt.Errorf("failed to retrieve the HPA of the kube-apiserver autoscaler in namespace '%s': %w", b.namespace, err)
+	}
+
+	vpa := b.emptyVpa()
+	if err := b.seedClient.Get(ctx, client.ObjectKeyFromObject(vpa), vpa); err != nil {
+		if apierrors.IsNotFound(err) {
+			return &DesiredStateParameters{IsEnabled: false}, nil
+		}
+		return nil, fmt.Errorf("failed to retrieve the VPA of the kube-apiserver autoscaler in namespace '%s': %w", b.namespace, err)
+	}
+
+	desiredState := &DesiredStateParameters{
+		IsEnabled:       true,
+		MinReplicaCount: pointer.Int32Deref(hpa.Spec.MinReplicas, 0),
+		MaxReplicaCount: hpa.Spec.MaxReplicas,
+	}
+	if hpa.Spec.Behavior != nil {
+		desiredState.ScaleUpRules = hpa.Spec.Behavior.ScaleUp
+	}
+	if len(hpa.Spec.Metrics) > 1 {
+		desiredState.AdditionalMetrics = hpa.Spec.Metrics[1:]
+	}
+
+	return desiredState, nil
+}
+
+// CheckMetricsAvailability implements Interface.
+func (b *bipa) CheckMetricsAvailability(ctx context.Context) error {
+	hpa := b.emptyHpa()
+	if err := b.seedClient.Get(ctx, client.ObjectKeyFromObject(hpa), hpa); err != nil {
+		return fmt.Errorf("failed to retrieve the HPA of the kube-apiserver autoscaler in namespace '%s': %w", b.namespace, err)
+	}
+
+	for _, condition := range hpa.Status.Conditions {
+		if condition.Type == autoscalingv2beta2.ScalingActive &&
+			condition.Status == corev1.ConditionFalse &&
+			condition.Reason == "FailedGetPodsMetric" {
+
+			return &MetricsUnavailableError{Message: condition.Message}
+		}
+	}
+
+	return nil
+}
+
+// labelShootAccessSecret places the scrape-target discovery label on the already reconciled shoot access secret. An
+// empty labelValue selects the default value.
+func (b *bipa) labelShootAccessSecret(ctx context.Context, secret *corev1.Secret, labelValue string) error {
+	if labelValue == "" {
+		labelValue = defaultShootAccessSecretLabelValue
+	}
+
+	patch := client.MergeFrom(secret.DeepCopy())
+	metav1.SetMetaDataLabel(&secret.ObjectMeta, shootAccessSecretLabelKey, labelValue)
+	return b.seedClient.Patch(ctx, secret, patch)
+}
+
+func (b *bipa) emptyManagedResource() *resourcesv1alpha1.ManagedResource {
+	return &resourcesv1alpha1.ManagedResource{ObjectMeta: metav1.ObjectMeta{Name: managedResourceTargetName, Namespace: b.namespace}}
+}
+
+func (b *bipa) emptyManagedResourceSecret() *corev1.Secret {
+	return &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: managedresources.SecretName(managedResourceTargetName, true), Namespace: b.namespace}}
+}
+
+// computeShootResourcesData returns the serialized shoot-side app resources: the RBAC objects, named after
+// clusterRoleName, which permit the specified service account to scrape the shoot kube-apiserver's metrics endpoint.
+func (b *bipa) computeShootResourcesData(serviceAccountName, clusterRoleName string) (map[string][]byte, error) {
+	var (
+		registry = managedresources.NewRegistry(kubernetes.ShootScheme, kubernetes.ShootCodec, kubernetes.ShootSerializer)
+
+		clusterRole = &rbacv1.ClusterRole{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: clusterRoleName,
+			},
+			Rules: []rbacv1.PolicyRule{{
+				NonResourceURLs: []string{"/metrics"},
+				Verbs:           []string{"get"},
+			}},
+		}
+
+		clusterRoleBinding = &rbacv1.ClusterRoleBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: clusterRoleName,
+			},
+			RoleRef: rbacv1.RoleRef{
+				APIGroup: rbacv1.GroupName,
+				Kind:     "ClusterRole",
+				Name:     clusterRole.Name,
+			},
+			Subjects: []rbacv1.Subject{{
+				Kind:      rbacv1.ServiceAccountKind,
+				Name:      serviceAccountName,
+				Namespace: metav1.NamespaceSystem,
+			}},
+		}
+	)
+
+	return registry.AddAllAndSerialize(
+		clusterRole,
+		clusterRoleBinding,
+	)
+}
@@ -0,0 +1,647 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bipa_test
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gardener/gardener/pkg/client/kubernetes"
+	. "github.com/gardener/gardener/pkg/operation/botanist/component/bipa"
+	"github.com/gardener/gardener/pkg/operation/botanist/component/gardenercustommetrics"
+	kutil "github.com/gardener/gardener/pkg/utils/kubernetes"
+	"github.com/gardener/gardener/pkg/utils/managedresources"
+	. "github.com/gardener/gardener/pkg/utils/test/matchers"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gstruct"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	vpaautoscalingv1 "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+var _ = Describe("Bipa", func() {
+	const (
+		namespace      = "shoot--foo--bar"
+		autoscalerName = "kube-apiserver-bipa"
+	)
+
+	var (
+		ctx        context.Context
+		seedClient client.Client
+	)
+
+	BeforeEach(func() {
+		ctx = context.TODO()
+		seedClient = fakeclient.NewClientBuilder().WithScheme(kubernetes.SeedScheme).Build()
+	})
+
+	// Deploys bipa with the specified scaling range and returns the resulting HPA and VPA objects.
+	deployAndGetAutoscalers := func(minReplicaCount, maxReplicaCount int32) (*autoscalingv2beta2.HorizontalPodAutoscaler, *vpaautoscalingv1.VerticalPodAutoscaler) {
+		deployer := New(seedClient, namespace, nil, DesiredStateParameters{
+			IsEnabled:       true,
+			MinReplicaCount: minReplicaCount,
+			MaxReplicaCount: maxReplicaCount,
+		})
+		ExpectWithOffset(1, deployer.Deploy(ctx)).To(Succeed())
+
+		hpa := &autoscalingv2beta2.HorizontalPodAutoscaler{}
+		ExpectWithOffset(1, seedClient.Get(ctx, kutil.Key(namespace, autoscalerName), hpa)).To(Succeed())
+		vpa := &vpaautoscalingv1.VerticalPodAutoscaler{}
+		ExpectWithOffset(1, seedClient.Get(ctx, kutil.Key(namespace, autoscalerName), vpa)).To(Succeed())
+
+		return hpa, vpa
+	}
+
+	Describe("#Deploy", func() {
+		It("should apply the scaling range to the HPA and VPA", func() {
+			hpa, vpa := deployAndGetAutoscalers(2, 4)
+
+			Expect(hpa.Spec.MinReplicas).To(PointTo(Equal(int32(2))))
+			Expect(hpa.Spec.MaxReplicas).To(Equal(int32(4)))
+			Expect(vpa.Spec.UpdatePolicy.MinReplicas).To(PointTo(Equal(int32(2))))
+		})
+
+		It("should keep the VPA's MinReplicas below the HPA's MaxReplicas if the scaling range is collapsed", func() {
+			hpa, vpa := deployAndGetAutoscalers(4, 4)
+
+			Expect(hpa.Spec.MinReplicas).To(PointTo(Equal(int32(4))))
+			Expect(hpa.Spec.MaxReplicas).To(Equal(int32(4)))
+			Expect(vpa.Spec.UpdatePolicy.MinReplicas).To(PointTo(Equal(int32(3))))
+		})
+
+		It("should not reduce the VPA's MinReplicas below one", func() {
+			_, vpa := deployAndGetAutoscalers(1, 1)
+
+			Expect(vpa.Spec.UpdatePolicy.MinReplicas).To(PointTo(Equal(int32(1))))
+		})
+
+		It("should refuse a scaling range with a lower bound below one", func() {
+			deployer := New(seedClient, namespace, nil, DesiredStateParameters{
+				IsEnabled:       true,
+				MinReplicaCount: 0,
+				MaxReplicaCount: 4,
+			})
+
+			err := deployer.Deploy(ctx)
+
+			Expect(errors.Is(err, ErrInvalidReplicaRange)).To(BeTrue())
+			Expect(err).To(MatchError(ContainSubstring("below one")))
+		})
+
+		It("should refuse a scaling range with an upper bound below its lower bound", func() {
+			deployer := New(seedClient, namespace, nil, DesiredStateParameters{
+				IsEnabled:       true,
+				MinReplicaCount: 4,
+				MaxReplicaCount: 2,
+			})
+
+			err := deployer.Deploy(ctx)
+
+			Expect(errors.Is(err, ErrInvalidReplicaRange)).To(BeTrue())
+			Expect(err).To(MatchError(ContainSubstring("below the lower bound")))
+		})
+
+		It("should scale on the custom metric provided by gardener-custom-metrics", func() {
+			hpa, _ := deployAndGetAutoscalers(1, 4)
+
+			Expect(hpa.Spec.Metrics[0].Pods.Metric.Name).To(Equal(gardenercustommetrics.APIServerRequestRateMetricName))
+		})
+
+		It("should leave the HPA's scaling behavior at the Kubernetes defaults if no scale-up rules are configured", func() {
+			hpa, _ := deployAndGetAutoscalers(1, 4)
+
+			Expect(hpa.Spec.Behavior).To(BeNil())
+		})
+
+		It("should propagate configured scale-up rules to the HPA", func() {
+			scaleUpRules := &autoscalingv2beta2.HPAScalingRules{
+				StabilizationWindowSeconds: pointer.Int32(60),
+				Policies: []autoscalingv2beta2.HPAScalingPolicy{{
+					Type:          autoscalingv2beta2.PercentScalingPolicy,
+					Value:         100,
+					PeriodSeconds: 30,
+				}},
+			}
+			deployer := New(seedClient, namespace, nil, DesiredStateParameters{
+				IsEnabled:       true,
+				MinReplicaCount: 1,
+				MaxReplicaCount: 4,
+				ScaleUpRules:    scaleUpRules,
+			})
+			Expect(deployer.Deploy(ctx)).To(Succeed())
+
+			hpa := &autoscalingv2beta2.HorizontalPodAutoscaler{}
+			Expect(seedClient.Get(ctx, kutil.Key(namespace, autoscalerName), hpa)).To(Succeed())
+			Expect(hpa.Spec.Behavior).NotTo(BeNil())
+			Expect(hpa.Spec.Behavior.ScaleUp).To(Equal(scaleUpRules))
+			Expect(hpa.Spec.Behavior.ScaleDown).To(BeNil())
+		})
+
+		It("should append configured additional metrics to the built-in custom metric", func() {
+			cpuMetric := autoscalingv2beta2.MetricSpec{
+				Type: autoscalingv2beta2.ResourceMetricSourceType,
+				Resource: &autoscalingv2beta2.ResourceMetricSource{
+					Name: corev1.ResourceCPU,
+					Target: autoscalingv2beta2.MetricTarget{
+						Type:               autoscalingv2beta2.UtilizationMetricType,
+						AverageUtilization: pointer.Int32(80),
+					},
+				},
+			}
+			deployer := New(seedClient, namespace, nil, DesiredStateParameters{
+				IsEnabled:         true,
+				MinReplicaCount:   1,
+				MaxReplicaCount:   4,
+				AdditionalMetrics: []autoscalingv2beta2.MetricSpec{cpuMetric},
+			})
+			Expect(deployer.Deploy(ctx)).To(Succeed())
+
+			hpa := &autoscalingv2beta2.HorizontalPodAutoscaler{}
+			Expect(seedClient.Get(ctx, kutil.Key(namespace, autoscalerName), hpa)).To(Succeed())
+			Expect(hpa.Spec.Metrics).To(HaveLen(2))
+			Expect(hpa.Spec.Metrics[0].Pods.Metric.Name).To(Equal(MetricName))
+			Expect(hpa.Spec.Metrics[1]).To(Equal(cpuMetric))
+		})
+
+		It("should refuse additional metrics which duplicate the built-in custom metric", func() {
+			targetAverageValue := resource.MustParse("100")
+			deployer := New(seedClient, namespace, nil, DesiredStateParameters{
+				IsEnabled:       true,
+				MinReplicaCount: 1,
+				MaxReplicaCount: 4,
+				AdditionalMetrics: []autoscalingv2beta2.MetricSpec{{
+					Type: autoscalingv2beta2.PodsMetricSourceType,
+					Pods: &autoscalingv2beta2.PodsMetricSource{
+						Metric: autoscalingv2beta2.MetricIdentifier{Name: MetricName},
+						Target: autoscalingv2beta2.MetricTarget{
+							Type:         autoscalingv2beta2.AverageValueMetricType,
+							AverageValue: &targetAverageValue,
+						},
+					},
+				}},
+			})
+
+			Expect(deployer.Deploy(ctx)).To(MatchError(ContainSubstring("duplicates")))
+		})
+
+		It("should not set owner references on the HPA and VPA by default", func() {
+			hpa, vpa := deployAndGetAutoscalers(1, 4)
+
+			Expect(hpa.OwnerReferences).To(BeEmpty())
+			Expect(vpa.OwnerReferences).To(BeEmpty())
+		})
+
+		It("should set the kube-apiserver deployment as owner of the HPA and VPA if enabled", func() {
+			apiserverDeployment := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "kube-apiserver", Namespace: namespace, UID: "test-uid"},
+			}
+			Expect(seedClient.Create(ctx, apiserverDeployment)).To(Succeed())
+
+			deployer := New(seedClient, namespace, nil, DesiredStateParameters{
+				IsEnabled:           true,
+				MinReplicaCount:     1,
+				MaxReplicaCount:     4,
+				SetApiserverAsOwner: true,
+			})
+			Expect(deployer.Deploy(ctx)).To(Succeed())
+
+			expectedOwnerReference := metav1.OwnerReference{
+				APIVersion: "apps/v1",
+				Kind:       "Deployment",
+				Name:       "kube-apiserver",
+				UID:        "test-uid",
+			}
+			hpa := &autoscalingv2beta2.HorizontalPodAutoscaler{}
+			Expect(seedClient.Get(ctx, kutil.Key(namespace, autoscalerName), hpa)).To(Succeed())
+			Expect(hpa.OwnerReferences).To(ConsistOf(expectedOwnerReference))
+			vpa := &vpaautoscalingv1.VerticalPodAutoscaler{}
+			Expect(seedClient.Get(ctx, kutil.Key(namespace, autoscalerName), vpa)).To(Succeed())
+			Expect(vpa.OwnerReferences).To(ConsistOf(expectedOwnerReference))
+		})
+
+		It("should fail if owner references are enabled but the kube-apiserver deployment does not exist", func() {
+			deployer := New(seedClient, namespace, nil, DesiredStateParameters{
+				IsEnabled:           true,
+				MinReplicaCount:     1,
+				MaxReplicaCount:     4,
+				SetApiserverAsOwner: true,
+			})
+
+			Expect(deployer.Deploy(ctx)).To(MatchError(ContainSubstring("owner reference")))
+		})
+
+		It("should label the shoot access secret for scrape-target discovery by default", func() {
+			deployAndGetAutoscalers(1, 4)
+
+			secret := &corev1.Secret{}
+			Expect(seedClient.Get(ctx, kutil.Key(namespace, "shoot-access-bipa"), secret)).To(Succeed())
+			Expect(secret.Labels).To(HaveKeyWithValue("name", "shoot-access-gardener-custom-metrics"))
+		})
+
+		It("should apply a configured shoot access secret label override", func() {
+			deployer := New(seedClient, namespace, nil, DesiredStateParameters{
+				IsEnabled:                   true,
+				MinReplicaCount:             1,
+				MaxReplicaCount:             4,
+				ShootAccessSecretLabelValue: "shoot-access-gardener-custom-metrics-test",
+			})
+			Expect(deployer.Deploy(ctx)).To(Succeed())
+
+			secret := &corev1.Secret{}
+			Expect(seedClient.Get(ctx, kutil.Key(namespace, "shoot-access-bipa"), secret)).To(Succeed())
+			Expect(secret.Labels).To(HaveKeyWithValue("name", "shoot-access-gardener-custom-metrics-test"))
+		})
+
+		It("should render shoot RBAC objects with a configured ClusterRole name override", func() {
+			deployer := New(seedClient, namespace, nil, DesiredStateParameters{
+				IsEnabled:            true,
+				MinReplicaCount:      1,
+				MaxReplicaCount:      4,
+				ShootClusterRoleName: "gardener.cloud:target:bipa-test",
+			})
+			Expect(deployer.Deploy(ctx)).To(Succeed())
+
+			managedResourceSecret, err := managedresources.GetSecret(ctx, seedClient, namespace, "shoot-core-bipa")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(managedResourceSecret.Data).To(HaveKey("clusterrole____gardener.cloud_target_bipa-test.yaml"))
+			Expect(managedResourceSecret.Data).To(HaveKey("clusterrolebinding____gardener.cloud_target_bipa-test.yaml"))
+			Expect(managedResourceSecret.Data).NotTo(HaveKey("clusterrole____gardener.cloud_target_bipa.yaml"))
+		})
+
+		It("should deploy the shoot resources by default", func() {
+			deployAndGetAutoscalers(1, 4)
+
+			Expect(seedClient.Get(ctx, kutil.Key(namespace, "managedresource-shoot-core-bipa"), &corev1.Secret{})).
+				To(Succeed())
+		})
+
+		It("should remove previously deployed shoot resources if their deployment is disabled", func() {
+			deployAndGetAutoscalers(1, 4)
+
+			deployer := New(seedClient, namespace, nil, DesiredStateParameters{
+				IsEnabled:            true,
+				MinReplicaCount:      1,
+				MaxReplicaCount:      4,
+				DeployShootResources: pointer.Bool(false),
+			})
+			Expect(deployer.Deploy(ctx)).To(Succeed())
+
+			Expect(seedClient.Get(ctx, kutil.Key(namespace, "managedresource-shoot-core-bipa"), &corev1.Secret{})).
+				To(BeNotFoundError())
+			Expect(seedClient.Get(ctx, kutil.Key(namespace, "shoot-access-bipa"), &corev1.Secret{})).
+				To(BeNotFoundError())
+			// The autoscalers themselves remain in place
+			Expect(seedClient.Get(ctx, kutil.Key(namespace, autoscalerName),
+				&autoscalingv2beta2.HorizontalPodAutoscaler{})).To(Succeed())
+		})
+
+		It("should remove the autoscaler if it is disabled", func() {
+			deployAndGetAutoscalers(1, 4)
+
+			deployer := New(seedClient, namespace, nil, DesiredStateParameters{IsEnabled: false})
+			Expect(deployer.Deploy(ctx)).To(Succeed())
+
+			hpaList := &autoscalingv2beta2.HorizontalPodAutoscalerList{}
+			Expect(seedClient.List(ctx, hpaList, client.InNamespace(namespace))).To(Succeed())
+			Expect(hpaList.Items).To(BeEmpty())
+			vpaList := &vpaautoscalingv1.VerticalPodAutoscalerList{}
+			Expect(seedClient.List(ctx, vpaList, client.InNamespace(namespace))).To(Succeed())
+			Expect(vpaList.Items).To(BeEmpty())
+		})
+	})
+
+	Describe("#GetShootAccessSecretName", func() {
+		It("should return the name of the secret created during reconciliation", func() {
+			deployer := New(seedClient, namespace, nil, DesiredStateParameters{
+				IsEnabled:       true,
+				MinReplicaCount: 1,
+				MaxReplicaCount: 4,
+			})
+			Expect(deployer.Deploy(ctx)).To(Succeed())
+
+			Expect(seedClient.Get(ctx, kutil.Key(namespace, deployer.GetShootAccessSecretName()), &corev1.Secret{})).
+				To(Succeed())
+		})
+	})
+
+	Describe("#Reconcile events", func() {
+		It("should emit events about notable reconciliation outcomes", func() {
+			eventRecorder := record.NewFakeRecorder(10)
+			deployer := New(seedClient, namespace, eventRecorder, DesiredStateParameters{
+				IsEnabled:       true,
+				MinReplicaCount: 1,
+				MaxReplicaCount: 4,
+			})
+
+			Expect(deployer.Deploy(ctx)).To(Succeed())
+			Expect(eventRecorder.Events).To(Receive(ContainSubstring("Reconciled")))
+			Expect(eventRecorder.Events).NotTo(Receive())
+
+			// A subsequent reconciliation which changes the replica range additionally reports that change
+			_, err := deployer.Reconcile(ctx, DesiredStateParameters{
+				IsEnabled:       true,
+				MinReplicaCount: 2,
+				MaxReplicaCount: 5,
+			})
+			Expect(err).To(Succeed())
+			Expect(eventRecorder.Events).To(Receive(And(
+				ContainSubstring("ReplicaRangeChanged"),
+				ContainSubstring("[2, 5]"),
+			)))
+			Expect(eventRecorder.Events).To(Receive(ContainSubstring("Reconciled")))
+		})
+
+		It("should emit a warning event if the HPA cannot retrieve the custom metric", func() {
+			eventRecorder := record.NewFakeRecorder(10)
+			deployer := New(seedClient, namespace, eventRecorder, DesiredStateParameters{
+				IsEnabled:       true,
+				MinReplicaCount: 1,
+				MaxReplicaCount: 4,
+			})
+			Expect(deployer.Deploy(ctx)).To(Succeed())
+			Expect(eventRecorder.Events).To(Receive(ContainSubstring("Reconciled")))
+
+			hpa := &autoscalingv2beta2.HorizontalPodAutoscaler{}
+			Expect(seedClient.Get(ctx, kutil.Key(namespace, autoscalerName), hpa)).To(Succeed())
+			hpa.Status.Conditions = []autoscalingv2beta2.HorizontalPodAutoscalerCondition{{
+				Type:    autoscalingv2beta2.ScalingActive,
+				Status:  corev1.ConditionFalse,
+				Reason:  "FailedGetPodsMetric",
+				Message: "unable to get metric",
+			}}
+			Expect(seedClient.Update(ctx, hpa)).To(Succeed())
+
+			Expect(deployer.Deploy(ctx)).To(Succeed())
+			Expect(eventRecorder.Events).To(Receive(ContainSubstring("MetricsUnavailable")))
+		})
+	})
+
+	Describe("#GetDesiredState", func() {
+		It("should reconstruct the scaling range from the server-side objects", func() {
+			deployer := New(seedClient, namespace, nil, DesiredStateParameters{
+				IsEnabled:       true,
+				MinReplicaCount: 2,
+				MaxReplicaCount: 5,
+			})
+			Expect(deployer.Deploy(ctx)).To(Succeed())
+
+			desiredState, err := deployer.GetDesiredState(ctx)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(desiredState.IsEnabled).To(BeTrue())
+			Expect(desiredState.MinReplicaCount).To(Equal(int32(2)))
+			Expect(desiredState.MaxReplicaCount).To(Equal(int32(5)))
+			Expect(desiredState.ScaleUpRules).To(BeNil())
+			Expect(desiredState.AdditionalMetrics).To(BeEmpty())
+		})
+
+		It("should reconstruct configured scale-up rules", func() {
+			scaleUpRules := &autoscalingv2beta2.HPAScalingRules{StabilizationWindowSeconds: pointer.Int32(60)}
+			deployer := New(seedClient, namespace, nil, DesiredStateParameters{
+				IsEnabled:       true,
+				MinReplicaCount: 1,
+				MaxReplicaCount: 4,
+				ScaleUpRules:    scaleUpRules,
+			})
+			Expect(deployer.Deploy(ctx)).To(Succeed())
+
+			desiredState, err := deployer.GetDesiredState(ctx)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(desiredState.ScaleUpRules).To(Equal(scaleUpRules))
+		})
+
+		It("should report a disabled state if the autoscaler's objects do not exist", func() {
+			deployer := New(seedClient, namespace, nil, DesiredStateParameters{IsEnabled: true})
+
+			desiredState, err := deployer.GetDesiredState(ctx)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(desiredState.IsEnabled).To(BeFalse())
+		})
+	})
+
+	Describe("#CheckMetricsAvailability", func() {
+		// Deploys bipa and patches the specified conditions into its HPA's status.
+		deployWithHpaConditions := func(conditions ...autoscalingv2beta2.HorizontalPodAutoscalerCondition) Interface {
+			deployer := New(seedClient, namespace, nil, DesiredStateParameters{
+				IsEnabled:       true,
+				MinReplicaCount: 1,
+				MaxReplicaCount: 4,
+			})
+			ExpectWithOffset(1, deployer.Deploy(ctx)).To(Succeed())
+
+			hpa := &autoscalingv2beta2.HorizontalPodAutoscaler{}
+			ExpectWithOffset(1, seedClient.Get(ctx, kutil.Key(namespace, autoscalerName), hpa)).To(Succeed())
+			hpa.Status.Conditions = conditions
+			ExpectWithOffset(1, seedClient.Update(ctx, hpa)).To(Succeed())
+
+			return deployer
+		}
+
+		It("should report no error for a healthy HPA status", func() {
+			deployer := deployWithHpaConditions(autoscalingv2beta2.HorizontalPodAutoscalerCondition{
+				Type:   autoscalingv2beta2.ScalingActive,
+				Status: corev1.ConditionTrue,
+				Reason: "ValidMetricFound",
+			})
+
+			Expect(deployer.CheckMetricsAvailability(ctx)).To(Succeed())
+		})
+
+		It("should report a typed error if the HPA cannot retrieve the custom metric", func() {
+			deployer := deployWithHpaConditions(autoscalingv2beta2.HorizontalPodAutoscalerCondition{
+				Type:    autoscalingv2beta2.ScalingActive,
+				Status:  corev1.ConditionFalse,
+				Reason:  "FailedGetPodsMetric",
+				Message: "unable to get metric shoot:apiserver_request_total:sum",
+			})
+
+			err := deployer.CheckMetricsAvailability(ctx)
+
+			metricsUnavailableError := &MetricsUnavailableError{}
+			Expect(errors.As(err, &metricsUnavailableError)).To(BeTrue())
+			Expect(err).To(MatchError(ContainSubstring("unable to get metric")))
+		})
+
+		It("should fail if the HPA does not exist", func() {
+			deployer := New(seedClient, namespace, nil, DesiredStateParameters{IsEnabled: true})
+
+			Expect(deployer.CheckMetricsAvailability(ctx)).To(MatchError(ContainSubstring("failed to retrieve the HPA")))
+		})
+	})
+
+	Describe("#Reconcile", func() {
+		It("should remove all existing resources if the desired state is disabled", func() {
+			deployAndGetAutoscalers(1, 4)
+
+			deployer := New(seedClient, namespace, nil, DesiredStateParameters{
+				IsEnabled:       true,
+				MinReplicaCount: 1,
+				MaxReplicaCount: 4,
+			})
+			_, err := deployer.Reconcile(ctx, DesiredStateParameters{IsEnabled: false})
+			Expect(err).To(Succeed())
+
+			Expect(seedClient.Get(ctx, kutil.Key(namespace, autoscalerName),
+				&autoscalingv2beta2.HorizontalPodAutoscaler{})).To(BeNotFoundError())
+			Expect(seedClient.Get(ctx, kutil.Key(namespace, autoscalerName),
+				&vpaautoscalingv1.VerticalPodAutoscaler{})).To(BeNotFoundError())
+			Expect(seedClient.Get(ctx, kutil.Key(namespace, "shoot-access-bipa"), &corev1.Secret{})).
+				To(BeNotFoundError())
+			Expect(seedClient.Get(ctx, kutil.Key(namespace, "managedresource-shoot-core-bipa"), &corev1.Secret{})).
+				To(BeNotFoundError())
+		})
+
+		It("should refuse an empty namespace name without creating any server objects", func() {
+			deployer := New(seedClient, "", nil, DesiredStateParameters{})
+
+			_, err := deployer.Reconcile(ctx, DesiredStateParameters{
+				IsEnabled:       true,
+				MinReplicaCount: 1,
+				MaxReplicaCount: 4,
+			})
+
+			Expect(err).To(MatchError(ContainSubstring("namespace is empty")))
+			hpaList := &autoscalingv2beta2.HorizontalPodAutoscalerList{}
+			Expect(seedClient.List(ctx, hpaList)).To(Succeed())
+			Expect(hpaList.Items).To(BeEmpty())
+			vpaList := &vpaautoscalingv1.VerticalPodAutoscalerList{}
+			Expect(seedClient.List(ctx, vpaList)).To(Succeed())
+			Expect(vpaList.Items).To(BeEmpty())
+		})
+
+		It("should refuse a namespace name which is not a valid Kubernetes object name", func() {
+			deployer := New(seedClient, "shoot/foo", nil, DesiredStateParameters{})
+
+			_, err := deployer.Reconcile(ctx, DesiredStateParameters{
+				IsEnabled:       true,
+				MinReplicaCount: 1,
+				MaxReplicaCount: 4,
+			})
+
+			Expect(err).To(MatchError(ContainSubstring("not a valid Kubernetes namespace name")))
+		})
+
+		It("should report created objects on first reconcile and unchanged objects on an immediate repetition", func() {
+			desiredState := DesiredStateParameters{
+				IsEnabled:       true,
+				MinReplicaCount: 1,
+				MaxReplicaCount: 4,
+			}
+			deployer := New(seedClient, namespace, nil, desiredState)
+
+			result, err := deployer.Reconcile(ctx, desiredState)
+
+			Expect(err).To(Succeed())
+			Expect(result.HpaOperation).To(Equal(controllerutil.OperationResultCreated))
+			Expect(result.VpaOperation).To(Equal(controllerutil.OperationResultCreated))
+
+			result, err = deployer.Reconcile(ctx, desiredState)
+
+			Expect(err).To(Succeed())
+			Expect(result.HpaOperation).To(Equal(controllerutil.OperationResultNone))
+			Expect(result.VpaOperation).To(Equal(controllerutil.OperationResultNone))
+		})
+
+		It("should report an updated HPA and an unchanged VPA if only the HPA's desired state changed", func() {
+			deployer := New(seedClient, namespace, nil, DesiredStateParameters{
+				IsEnabled:       true,
+				MinReplicaCount: 1,
+				MaxReplicaCount: 4,
+			})
+			Expect(deployer.Deploy(ctx)).To(Succeed())
+
+			// Adding a scale-up rule changes the HPA, but leaves the VPA's desired state untouched
+			result, err := deployer.Reconcile(ctx, DesiredStateParameters{
+				IsEnabled:       true,
+				MinReplicaCount: 1,
+				MaxReplicaCount: 4,
+				ScaleUpRules:    &autoscalingv2beta2.HPAScalingRules{StabilizationWindowSeconds: pointer.Int32(60)},
+			})
+
+			Expect(err).To(Succeed())
+			Expect(result.HpaOperation).To(Equal(controllerutil.OperationResultUpdated))
+			Expect(result.VpaOperation).To(Equal(controllerutil.OperationResultNone))
+		})
+
+		It("should not write to the autoscaler objects when repeating a reconciliation with unchanged desired state", func() {
+			desiredState := DesiredStateParameters{
+				IsEnabled:       true,
+				MinReplicaCount: 1,
+				MaxReplicaCount: 4,
+			}
+			countingClient := &autoscalerWriteCountingClient{Client: seedClient}
+			deployer := New(countingClient, namespace, nil, desiredState)
+
+			_, err := deployer.Reconcile(ctx, desiredState)
+
+			Expect(err).To(Succeed())
+			Expect(countingClient.autoscalerWriteCount).To(Equal(2), "the first reconciliation creates the HPA and the VPA")
+
+			countingClient.autoscalerWriteCount = 0
+			result, err := deployer.Reconcile(ctx, desiredState)
+
+			Expect(err).To(Succeed())
+			Expect(countingClient.autoscalerWriteCount).To(BeZero())
+			Expect(result.HpaOperation).To(Equal(controllerutil.OperationResultNone))
+			Expect(result.VpaOperation).To(Equal(controllerutil.OperationResultNone))
+		})
+	})
+})
+
+// autoscalerWriteCountingClient wraps a client.Client and counts the write requests addressed to HPA or VPA objects,
+// so that tests can assert that a reconciliation left the autoscaler objects untouched.
+type autoscalerWriteCountingClient struct {
+	client.Client
+	autoscalerWriteCount int
+}
+
+func (c *autoscalerWriteCountingClient) countIfAutoscaler(obj client.Object) {
+	switch obj.(type) {
+	case *autoscalingv2beta2.HorizontalPodAutoscaler, *vpaautoscalingv1.VerticalPodAutoscaler:
+		c.autoscalerWriteCount++
+	}
+}
+
+func (c *autoscalerWriteCountingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	c.countIfAutoscaler(obj)
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *autoscalerWriteCountingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	c.countIfAutoscaler(obj)
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c *autoscalerWriteCountingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	c.countIfAutoscaler(obj)
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (c *autoscalerWriteCountingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	c.countIfAutoscaler(obj)
+	return c.Client.Delete(ctx, obj, opts...)
+}
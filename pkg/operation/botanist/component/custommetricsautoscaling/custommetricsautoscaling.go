@@ -0,0 +1,109 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package custommetricsautoscaling combines the two halves of the custom-metrics-based kube-apiserver autoscaling
+// feature under a single component handle: the seed-side gardener-custom-metrics metric provider, and the per-shoot
+// kube-apiserver autoscalers which scale on the metric it serves. The two halves are otherwise reconciled
+// independently, with no coordination of their relative order.
+package custommetricsautoscaling
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gardener/gardener/pkg/operation/botanist/component"
+)
+
+// New creates a component.DeployWaiter which manages the custom-metrics autoscaling feature as a whole. The
+// metricsProvider parameter is the deployer of the gardener-custom-metrics component; the autoscalers parameter holds
+// the deployers of the individual shoots' kube-apiserver autoscalers, which consume the provider's metric.
+//
+// Deploy first deploys the metric provider and waits for it to become healthy, and only then deploys the autoscalers -
+// so that no autoscaler starts scaling on a metric which is not served yet. Destroy proceeds in the reverse order: it
+// removes the autoscalers and waits for their cleanup to complete, and only then removes the metric provider - so that
+// no autoscaler is left scaling on a metric whose provider is gone.
+func New(metricsProvider component.DeployWaiter, autoscalers ...component.DeployWaiter) component.DeployWaiter {
+	return &customMetricsAutoscaling{
+		metricsProvider: metricsProvider,
+		autoscalers:     autoscalers,
+	}
+}
+
+type customMetricsAutoscaling struct {
+	metricsProvider component.DeployWaiter
+	autoscalers     []component.DeployWaiter
+}
+
+// Deploy implements component.Deployer. See New for the ordering guarantees.
+func (c *customMetricsAutoscaling) Deploy(ctx context.Context) error {
+	if err := c.metricsProvider.Deploy(ctx); err != nil {
+		return fmt.Errorf("failed to deploy the metric provider of the custom-metrics autoscaling feature: %w", err)
+	}
+
+	if err := c.metricsProvider.Wait(ctx); err != nil {
+		return fmt.Errorf(
+			"failed to confirm the health of the metric provider of the custom-metrics autoscaling feature before deploying the autoscalers which consume its metric: %w",
+			err)
+	}
+
+	for _, autoscaler := range c.autoscalers {
+		if err := autoscaler.Deploy(ctx); err != nil {
+			return fmt.Errorf("failed to deploy a kube-apiserver autoscaler of the custom-metrics autoscaling feature: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Destroy implements component.Deployer. See New for the ordering guarantees.
+func (c *customMetricsAutoscaling) Destroy(ctx context.Context) error {
+	for _, autoscaler := range c.autoscalers {
+		if err := autoscaler.Destroy(ctx); err != nil {
+			return fmt.Errorf("failed to remove a kube-apiserver autoscaler of the custom-metrics autoscaling feature: %w", err)
+		}
+		if err := autoscaler.WaitCleanup(ctx); err != nil {
+			return fmt.Errorf(
+				"failed to confirm the removal of a kube-apiserver autoscaler of the custom-metrics autoscaling feature before removing the provider of the metric it consumes: %w",
+				err)
+		}
+	}
+
+	if err := c.metricsProvider.Destroy(ctx); err != nil {
+		return fmt.Errorf("failed to remove the metric provider of the custom-metrics autoscaling feature: %w", err)
+	}
+
+	return nil
+}
+
+// Wait implements component.Waiter. The metric provider's health is already awaited as part of Deploy, so only the
+// autoscalers remain to be waited for here.
+func (c *customMetricsAutoscaling) Wait(ctx context.Context) error {
+	for _, autoscaler := range c.autoscalers {
+		if err := autoscaler.Wait(ctx); err != nil {
+			return fmt.Errorf("failed to confirm the health of a kube-apiserver autoscaler of the custom-metrics autoscaling feature: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// WaitCleanup implements component.Waiter. The autoscalers' cleanup is already awaited as part of Destroy, so only
+// the metric provider remains to be waited for here.
+func (c *customMetricsAutoscaling) WaitCleanup(ctx context.Context) error {
+	if err := c.metricsProvider.WaitCleanup(ctx); err != nil {
+		return fmt.Errorf("failed to confirm the removal of the metric provider of the custom-metrics autoscaling feature: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package custommetricsautoscaling_test
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/gardener/gardener/pkg/operation/botanist/component/custommetricsautoscaling"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// recordingDeployWaiter records the operations invoked on it into a shared journal, so that tests can assert the
+// relative order of operations across multiple components.
+type recordingDeployWaiter struct {
+	name    string
+	journal *[]string
+
+	// failingOperation, if non-empty, names the operation which fails when invoked.
+	failingOperation string
+}
+
+func (r *recordingDeployWaiter) record(operation string) error {
+	*r.journal = append(*r.journal, r.name+"."+operation)
+	if r.failingOperation == operation {
+		return fmt.Errorf("%s.%s failed", r.name, operation)
+	}
+	return nil
+}
+
+func (r *recordingDeployWaiter) Deploy(_ context.Context) error      { return r.record("Deploy") }
+func (r *recordingDeployWaiter) Destroy(_ context.Context) error     { return r.record("Destroy") }
+func (r *recordingDeployWaiter) Wait(_ context.Context) error        { return r.record("Wait") }
+func (r *recordingDeployWaiter) WaitCleanup(_ context.Context) error { return r.record("WaitCleanup") }
+
+var _ = Describe("CustomMetricsAutoscaling", func() {
+	var (
+		ctx         context.Context
+		journal     []string
+		provider    *recordingDeployWaiter
+		autoscaler1 *recordingDeployWaiter
+		autoscaler2 *recordingDeployWaiter
+	)
+
+	BeforeEach(func() {
+		ctx = context.TODO()
+		journal = nil
+		provider = &recordingDeployWaiter{name: "provider", journal: &journal}
+		autoscaler1 = &recordingDeployWaiter{name: "autoscaler1", journal: &journal}
+		autoscaler2 = &recordingDeployWaiter{name: "autoscaler2", journal: &journal}
+	})
+
+	Describe("#Deploy", func() {
+		It("should deploy the metric provider, confirm its health, and only then deploy the autoscalers", func() {
+			deployer := New(provider, autoscaler1, autoscaler2)
+
+			Expect(deployer.Deploy(ctx)).To(Succeed())
+
+			Expect(journal).To(Equal([]string{
+				"provider.Deploy",
+				"provider.Wait",
+				"autoscaler1.Deploy",
+				"autoscaler2.Deploy",
+			}))
+		})
+
+		It("should not deploy the autoscalers if the metric provider fails to become healthy", func() {
+			provider.failingOperation = "Wait"
+			deployer := New(provider, autoscaler1, autoscaler2)
+
+			Expect(deployer.Deploy(ctx)).To(MatchError(ContainSubstring("provider.Wait failed")))
+
+			Expect(journal).To(Equal([]string{"provider.Deploy", "provider.Wait"}))
+		})
+	})
+
+	Describe("#Destroy", func() {
+		It("should remove the autoscalers, confirm their removal, and only then remove the metric provider", func() {
+			deployer := New(provider, autoscaler1, autoscaler2)
+
+			Expect(deployer.Destroy(ctx)).To(Succeed())
+
+			Expect(journal).To(Equal([]string{
+				"autoscaler1.Destroy",
+				"autoscaler1.WaitCleanup",
+				"autoscaler2.Destroy",
+				"autoscaler2.WaitCleanup",
+				"provider.Destroy",
+			}))
+		})
+
+		It("should not remove the metric provider if an autoscaler's removal cannot be confirmed", func() {
+			autoscaler2.failingOperation = "WaitCleanup"
+			deployer := New(provider, autoscaler1, autoscaler2)
+
+			Expect(deployer.Destroy(ctx)).To(MatchError(ContainSubstring("autoscaler2.WaitCleanup failed")))
+
+			Expect(journal).To(Equal([]string{
+				"autoscaler1.Destroy",
+				"autoscaler1.WaitCleanup",
+				"autoscaler2.Destroy",
+				"autoscaler2.WaitCleanup",
+			}))
+		})
+	})
+
+	Describe("#Wait", func() {
+		It("should wait for the autoscalers only - the provider's health is confirmed as part of Deploy", func() {
+			deployer := New(provider, autoscaler1, autoscaler2)
+
+			Expect(deployer.Wait(ctx)).To(Succeed())
+
+			Expect(journal).To(Equal([]string{"autoscaler1.Wait", "autoscaler2.Wait"}))
+		})
+	})
+
+	Describe("#WaitCleanup", func() {
+		It("should wait for the provider only - the autoscalers' cleanup is confirmed as part of Destroy", func() {
+			deployer := New(provider, autoscaler1, autoscaler2)
+
+			Expect(deployer.WaitCleanup(ctx)).To(Succeed())
+
+			Expect(journal).To(Equal([]string{"provider.WaitCleanup"}))
+		})
+	})
+})
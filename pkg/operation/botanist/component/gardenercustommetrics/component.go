@@ -0,0 +1,998 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gardenercustommetrics implements the gardener-custom-metrics seed component (aka GCMx). For details, see
+// the GardenerCustomMetrics type.
+package gardenercustommetrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	gardencorev1alpha1 "github.com/gardener/gardener/pkg/apis/core/v1alpha1"
+	v1beta1constants "github.com/gardener/gardener/pkg/apis/core/v1beta1/constants"
+	resourcesv1alpha1 "github.com/gardener/gardener/pkg/apis/resources/v1alpha1"
+	"github.com/gardener/gardener/pkg/client/kubernetes"
+	"github.com/gardener/gardener/pkg/operation/botanist/component"
+	"github.com/gardener/gardener/pkg/utils"
+	kutil "github.com/gardener/gardener/pkg/utils/kubernetes"
+	"github.com/gardener/gardener/pkg/utils/managedresources"
+	"github.com/gardener/gardener/pkg/utils/retry"
+	secretutils "github.com/gardener/gardener/pkg/utils/secrets"
+	secretsmanager "github.com/gardener/gardener/pkg/utils/secrets/manager"
+	versionutils "github.com/gardener/gardener/pkg/utils/version"
+
+	"github.com/Masterminds/semver"
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apiregistrationv1 "k8s.io/kube-aggregator/pkg/apis/apiregistration/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// componentBaseName is the name which serves as base for naming the various artifacts deployed by this component.
+	componentBaseName = "gardener-custom-metrics"
+	// managedResourceName is the name of the ManagedResource which deploys the component's k8s objects to the seed.
+	managedResourceName = componentBaseName
+	// serverCertificateSecretName is the name of the secret holding GCMx's server TLS certificate.
+	serverCertificateSecretName = componentBaseName + "-tls"
+
+	// APIServerRequestRateMetricName is the name of the custom pods metric provided by GCMx, which reports the rate
+	// of requests served by an individual shoot kube-apiserver pod. Consumers scaling on the metric (e.g. the bipa
+	// HPA) reference this constant, so that producer and consumers cannot drift apart.
+	APIServerRequestRateMetricName = "shoot:apiserver_request_total:sum"
+)
+
+// TimeoutWaitForManagedResource is the timeout used while waiting for the ManagedResource to become healthy or
+// deleted. Exposed for testing.
+var TimeoutWaitForManagedResource = 2 * time.Minute
+
+// ErrCASecretMissing indicates that the secret expected to hold the CA which signs GCMx's server TLS certificate does
+// not exist - see WithCASecretName. The errors which wrap it carry the descriptive context; callers branch on the
+// condition via errors.Is.
+var ErrCASecretMissing = errors.New("the CA certificate secret is missing")
+
+var (
+	// deployManagedResourceRetryInterval is the wait period between the individual attempts to create the component's
+	// ManagedResource. Exposed as package variable solely to enable speeding up tests.
+	deployManagedResourceRetryInterval = 5 * time.Second
+	// deployManagedResourceMaxRetries is the number of times a transiently failing create of the component's
+	// ManagedResource is retried before the deploy operation is abandoned.
+	deployManagedResourceMaxRetries = 3
+	// waitForAPIServiceRetryInterval is the wait period between the individual polls of the APIService's availability
+	// condition. Exposed as package variable solely to enable speeding up tests.
+	waitForAPIServiceRetryInterval = 5 * time.Second
+	// waitForObservedGenerationRetryInterval is the wait period between the individual polls of the ManagedResource's
+	// observed generation. Exposed as package variable solely to enable speeding up tests.
+	waitForObservedGenerationRetryInterval = 5 * time.Second
+)
+
+// GardenerCustomMetrics manages the lifecycle of the gardener-custom-metrics component in a seed cluster (aka GCMx).
+// GCMx scrapes the metrics endpoints of the shoot kube-apiserver pods on the seed and provides derived, shoot-level
+// metrics via the custom metrics API, where they can drive the horizontal autoscaling of the respective
+// kube-apiservers.
+type GardenerCustomMetrics struct {
+	namespace          string
+	containerImageName string
+	isEnabled          bool
+	keepObjects        bool
+	kubernetesVersion  *semver.Version
+	seedClient         client.Client
+	secretsManager     secretsmanager.Interface
+	log                logr.Logger
+	replicas           int32
+	nodeSelector       map[string]string
+	tolerations        []corev1.Toleration
+	affinity           *corev1.Affinity
+
+	topologySpreadConstraints []corev1.TopologySpreadConstraint
+	livenessProbePath         string
+	readinessProbePath        string
+	probePort                 int32
+
+	additionalDNSNames        []string
+	ipAddresses               []net.IP
+	extraEnv                  []corev1.EnvVar
+	shootAPIServerEgressCIDRs []string
+
+	serviceSessionAffinity          corev1.ServiceAffinity
+	servicePublishNotReadyAddresses bool
+	extraVolumes                    []corev1.Volume
+	extraVolumeMounts               []corev1.VolumeMount
+
+	terminationGracePeriodSeconds int64
+	preStopSleepSeconds           int32
+	revisionHistoryLimit          *int32
+	deploymentStrategy            *appsv1.DeploymentStrategy
+	resourceLimits                corev1.ResourceList
+	dryRun                        bool
+	strictDestroy                 bool
+	caSecretName                  string
+	restrictedSecretNames         []string
+	registerAPIService            bool
+	containerName                 string
+	lastDeployedCertExpiry        *time.Time
+	scrapeInterval                *time.Duration
+	waitTimeout                   time.Duration
+
+	// testIsolation contains all points of indirection necessary to isolate static function calls in the
+	// GardenerCustomMetrics unit during tests
+	testIsolation gardenerCustomMetricsTestIsolation
+}
+
+// Option applies an optional setting to a GardenerCustomMetrics instance upon creation.
+type Option func(*GardenerCustomMetrics)
+
+// WithEnabled returns an option which controls whether the component should be present in the seed. With enablement
+// off, the instance deploys the absence of the component, i.e. removes any remnants of it which may be left in the
+// seed. The default is enabled.
+func WithEnabled(isEnabled bool) Option {
+	return func(gcmx *GardenerCustomMetrics) {
+		gcmx.isEnabled = isEnabled
+	}
+}
+
+// WithKeepObjects returns an option which controls whether deleting the component's ManagedResource leaves the
+// deployed objects in place - a debugging aid which should remain disabled in regular operation. The default is
+// disabled.
+func WithKeepObjects(keepObjects bool) Option {
+	return func(gcmx *GardenerCustomMetrics) {
+		gcmx.keepObjects = keepObjects
+	}
+}
+
+// WithKubernetesVersion returns an option which sets the version of the seed cluster, which gates version-specific
+// behavior - e.g. the version of the custom metrics API registered with the seed's aggregation layer. By default, the
+// version is unknown and the most recent supported behavior is applied.
+func WithKubernetesVersion(version *semver.Version) Option {
+	return func(gcmx *GardenerCustomMetrics) {
+		gcmx.kubernetesVersion = version
+	}
+}
+
+// WithLogger returns an option which directs the progress records of the component's operations to the specified
+// logger. By default, said records are discarded.
+func WithLogger(log logr.Logger) Option {
+	return func(gcmx *GardenerCustomMetrics) {
+		if log.GetSink() == nil {
+			log = logr.Discard()
+		}
+		gcmx.log = log
+	}
+}
+
+// WithNodeSelector returns an option which constrains the GCMx pod to nodes matching the specified selector. By
+// default, the pod is free to schedule on any seed node.
+func WithNodeSelector(nodeSelector map[string]string) Option {
+	return func(gcmx *GardenerCustomMetrics) {
+		gcmx.nodeSelector = nodeSelector
+	}
+}
+
+// WithTolerations returns an option which attaches the specified tolerations to the GCMx pod. By default, the pod
+// carries no tolerations.
+func WithTolerations(tolerations []corev1.Toleration) Option {
+	return func(gcmx *GardenerCustomMetrics) {
+		gcmx.tolerations = tolerations
+	}
+}
+
+// WithReplicas returns an option which sets the number of GCMx pod replicas. The default is a single replica. A
+// multi-replica deployment automatically receives a preferred pod anti-affinity which spreads the replicas across
+// nodes, unless an explicit affinity is specified via WithAffinity.
+func WithReplicas(replicas int32) Option {
+	return func(gcmx *GardenerCustomMetrics) {
+		gcmx.replicas = replicas
+	}
+}
+
+// WithAffinity returns an option which sets the affinity rules of the GCMx pod, overriding the rules which would
+// otherwise be derived from the replica count.
+func WithAffinity(affinity *corev1.Affinity) Option {
+	return func(gcmx *GardenerCustomMetrics) {
+		gcmx.affinity = affinity
+	}
+}
+
+// WithTopologySpreadConstraints returns an option which sets the topology spread constraints governing how the GCMx
+// pods are spread across the seed's topology domains. By default, no constraints are applied.
+func WithTopologySpreadConstraints(constraints []corev1.TopologySpreadConstraint) Option {
+	return func(gcmx *GardenerCustomMetrics) {
+		gcmx.topologySpreadConstraints = constraints
+	}
+}
+
+// WithProbeEndpoints returns an option which overrides the HTTPS paths and port at which the GCMx container serves
+// its liveness and readiness probe endpoints. An empty path or a zero port leaves the respective default in place.
+func WithProbeEndpoints(livenessPath, readinessPath string, port int32) Option {
+	return func(gcmx *GardenerCustomMetrics) {
+		gcmx.livenessProbePath = livenessPath
+		gcmx.readinessProbePath = readinessPath
+		gcmx.probePort = port
+	}
+}
+
+// WithAdditionalDNSNames returns an option which extends the DNS names covered by GCMx's server TLS certificate
+// beyond the names derived from the component's service - e.g. a stable seed-internal alias via which the component
+// is also reachable. Names which duplicate the derived ones are ignored. By default, no additional names are covered.
+func WithAdditionalDNSNames(dnsNames []string) Option {
+	return func(gcmx *GardenerCustomMetrics) {
+		gcmx.additionalDNSNames = dnsNames
+	}
+}
+
+// WithIPAddresses returns an option which makes GCMx's server TLS certificate cover the specified IP addresses, for
+// setups in which the component is addressed by IP rather than by service DNS name - e.g. an APIService configured
+// with full TLS verification against the advertised pod IP. By default, no IP addresses are covered.
+func WithIPAddresses(ipAddresses []net.IP) Option {
+	return func(gcmx *GardenerCustomMetrics) {
+		gcmx.ipAddresses = ipAddresses
+	}
+}
+
+// WithServiceSessionAffinity returns an option which sets the session affinity of the GCMx service. The default is
+// 'None'.
+func WithServiceSessionAffinity(sessionAffinity corev1.ServiceAffinity) Option {
+	return func(gcmx *GardenerCustomMetrics) {
+		gcmx.serviceSessionAffinity = sessionAffinity
+	}
+}
+
+// WithServicePublishNotReadyAddresses returns an option which controls whether the GCMx service also publishes the
+// addresses of not-ready pods. The default is disabled - only ready replicas serve the custom metrics API.
+func WithServicePublishNotReadyAddresses(publishNotReadyAddresses bool) Option {
+	return func(gcmx *GardenerCustomMetrics) {
+		gcmx.servicePublishNotReadyAddresses = publishNotReadyAddresses
+	}
+}
+
+// WithShootAPIServerEgressCIDRs returns an option which adds an explicit egress NetworkPolicy allowing the GCMx pods
+// TCP 443 egress to the specified shoot kube-apiserver CIDRs - for seeds where the label-driven 'to-shoot-apiserver'
+// policy is not in effect. By default, no such policy is deployed and egress is governed solely by the network policy
+// labels on the GCMx pod.
+func WithShootAPIServerEgressCIDRs(cidrs []string) Option {
+	return func(gcmx *GardenerCustomMetrics) {
+		gcmx.shootAPIServerEgressCIDRs = cidrs
+	}
+}
+
+// WithExtraEnv returns an option which appends the specified environment variables to the built-in variables of the
+// GCMx container - e.g. to configure an HTTP proxy. A variable whose name collides with a built-in one is dropped;
+// the built-in value wins. By default, no extra variables are set.
+func WithExtraEnv(env []corev1.EnvVar) Option {
+	return func(gcmx *GardenerCustomMetrics) {
+		gcmx.extraEnv = env
+	}
+}
+
+// WithExtraVolumes returns an option which appends the specified volumes to the built-in volumes of the GCMx pod -
+// e.g. to make an additional trust bundle available to the container. The volume names must not clash with the
+// built-in ones. By default, no extra volumes are attached.
+func WithExtraVolumes(volumes []corev1.Volume) Option {
+	return func(gcmx *GardenerCustomMetrics) {
+		gcmx.extraVolumes = volumes
+	}
+}
+
+// WithExtraVolumeMounts returns an option which appends the specified volume mounts to the built-in mounts of the
+// GCMx container, typically paired with WithExtraVolumes. By default, no extra mounts are attached.
+func WithExtraVolumeMounts(volumeMounts []corev1.VolumeMount) Option {
+	return func(gcmx *GardenerCustomMetrics) {
+		gcmx.extraVolumeMounts = volumeMounts
+	}
+}
+
+// WithTerminationGracePeriodSeconds returns an option which sets the time the kubelet grants the GCMx pod to shut
+// down gracefully. The default is 30 seconds.
+func WithTerminationGracePeriodSeconds(seconds int64) Option {
+	return func(gcmx *GardenerCustomMetrics) {
+		gcmx.terminationGracePeriodSeconds = seconds
+	}
+}
+
+// WithPreStopSleepSeconds returns an option which installs a preStop hook on the GCMx container, delaying its
+// termination signal by the specified duration so that in-flight custom metrics requests can bleed off during leader
+// handoff. By default, no hook is installed.
+func WithPreStopSleepSeconds(seconds int32) Option {
+	return func(gcmx *GardenerCustomMetrics) {
+		gcmx.preStopSleepSeconds = seconds
+	}
+}
+
+// WithRevisionHistoryLimit returns an option which sets the number of old ReplicaSets retained to allow rollbacks of
+// the GCMx deployment. The default is one.
+func WithRevisionHistoryLimit(limit int32) Option {
+	return func(gcmx *GardenerCustomMetrics) {
+		gcmx.revisionHistoryLimit = &limit
+	}
+}
+
+// WithDeploymentStrategy returns an option which overrides the rollout strategy of the GCMx deployment - e.g. a
+// 'Recreate' strategy for seeds where two GCMx instances must never serve concurrently. By default, the strategy is
+// left empty and the k8s default (rolling update) applies.
+func WithDeploymentStrategy(strategy *appsv1.DeploymentStrategy) Option {
+	return func(gcmx *GardenerCustomMetrics) {
+		gcmx.deploymentStrategy = strategy
+	}
+}
+
+// WithResourceLimits returns an option which caps the resource consumption of the GCMx container. The built-in
+// resource requests remain in place either way. If the container is later put under a VPA with 'RequestsOnly'
+// controlled values, the limits stay as configured here while the requests are adjusted. By default, no limits are
+// applied.
+func WithResourceLimits(limits corev1.ResourceList) Option {
+	return func(gcmx *GardenerCustomMetrics) {
+		gcmx.resourceLimits = limits
+	}
+}
+
+// WithDryRun returns an option which puts the instance in dry-run mode. In that mode, Destroy reports what it would
+// remove from the seed (see PlanDestroy) without performing any mutation. Intended for operators assessing the
+// impact of decommissioning the component across a fleet of seeds.
+func WithDryRun(dryRun bool) Option {
+	return func(gcmx *GardenerCustomMetrics) {
+		gcmx.dryRun = dryRun
+	}
+}
+
+// WithScrapeInterval returns an option which overrides the cadence at which GCMx scrapes the shoot kube-apiserver
+// metrics endpoints. By default, the GCMx binary's built-in cadence applies. Note that the scrape interval bounds
+// the resolution of the samples behind the shoot:apiserver_request_total:sum rate calculation which drives the
+// kube-apiserver HPA - intervals approaching that calculation's rate window make the resulting metric increasingly
+// noisy. Deploy rejects non-positive intervals.
+func WithScrapeInterval(interval time.Duration) Option {
+	return func(gcmx *GardenerCustomMetrics) {
+		gcmx.scrapeInterval = &interval
+	}
+}
+
+// WithContainerName returns an option which overrides the name of the GCMx container inside the deployment's pod.
+// The default is the component's base name. All rendered objects which reference the container by name derive from
+// the same value.
+func WithContainerName(name string) Option {
+	return func(gcmx *GardenerCustomMetrics) {
+		gcmx.containerName = name
+	}
+}
+
+// WithAPIServiceRegistration returns an option which controls whether GCMx registers with the seed's aggregation
+// layer as the provider of the custom metrics API - the default, and the mode required by the kube-apiserver HPA.
+// With registration disabled, the component only exposes its metrics for direct prometheus scraping (push mode) -
+// suitable for seeds which restrict APIService aggregation. See kubeObjectsParameters.RegisterAPIService for the
+// objects affected.
+func WithAPIServiceRegistration(register bool) Option {
+	return func(gcmx *GardenerCustomMetrics) {
+		gcmx.registerAPIService = register
+	}
+}
+
+// WithRestrictedSecretAccess returns an option which narrows the secrets access granted by the component's
+// ClusterRole to the specified secret names. By default, the access is unrestricted, like that to the other resources
+// the component reads. Note that RBAC cannot restrict list and watch requests by resource name, so the narrowed rule
+// only permits 'get' - suitable for security-sensitive seeds which accept the loss of watch-based cache updates.
+func WithRestrictedSecretAccess(secretNames []string) Option {
+	return func(gcmx *GardenerCustomMetrics) {
+		gcmx.restrictedSecretNames = secretNames
+	}
+}
+
+// WithCASecretName returns an option which sets the name of the secret holding the CA which signs GCMx's server TLS
+// certificate, and whose bundle is advertised to the seed kube-apiserver via the APIService object. The default is
+// the seed cluster CA. Intended for seeds which dedicate a distinct CA to extension APIServices.
+func WithCASecretName(name string) Option {
+	return func(gcmx *GardenerCustomMetrics) {
+		gcmx.caSecretName = name
+	}
+}
+
+// WithStrictDestroy returns an option which controls how Destroy reacts if an HPA in the seed still scales on the
+// custom metric provided by GCMx. Removing the component deletes the custom metrics APIService, which makes the seed
+// kube-apiserver return errors to such HPAs for their in-flight metric queries. By default, Destroy merely logs a
+// warning naming the affected HPAs and proceeds; in strict mode, it refuses to remove the component instead.
+func WithStrictDestroy(strict bool) Option {
+	return func(gcmx *GardenerCustomMetrics) {
+		gcmx.strictDestroy = strict
+	}
+}
+
+// WithWaitTimeout returns an option which sets the time budget within which Wait and WaitCleanup expect the
+// component's ManagedResource to become healthy, respectively to disappear. The default is
+// TimeoutWaitForManagedResource.
+func WithWaitTimeout(timeout time.Duration) Option {
+	return func(gcmx *GardenerCustomMetrics) {
+		gcmx.waitTimeout = timeout
+	}
+}
+
+// NewGardenerCustomMetrics creates a GardenerCustomMetrics instance. The namespace parameter is the seed namespace
+// into which GCMx is deployed - usually the garden namespace. All settings beyond these essentials are applied via
+// the trailing options; with no options given, the instance manages an enabled component with the documented
+// defaults. As a result, new optional settings extend the option set instead of growing (and breaking) the
+// constructor signature.
+func NewGardenerCustomMetrics(
+	namespace string,
+	containerImageName string,
+	seedClient client.Client,
+	secretsManager secretsmanager.Interface,
+	options ...Option,
+) *GardenerCustomMetrics {
+	gcmx := &GardenerCustomMetrics{
+		namespace:          namespace,
+		containerImageName: containerImageName,
+		isEnabled:          true,
+		seedClient:         seedClient,
+		secretsManager:     secretsManager,
+		log:                logr.Discard(),
+		replicas:           1,
+
+		terminationGracePeriodSeconds: defaultTerminationGracePeriodSeconds,
+		caSecretName:                  v1beta1constants.SecretNameCASeed,
+		registerAPIService:            true,
+		waitTimeout:                   TimeoutWaitForManagedResource,
+		testIsolation: gardenerCustomMetricsTestIsolation{
+			CreateForSeed:    managedresources.CreateForSeed,
+			DeleteForSeed:    managedresources.DeleteForSeed,
+			WaitUntilHealthy: managedresources.WaitUntilHealthy,
+			WaitUntilDeleted: managedresources.WaitUntilDeleted,
+			GenerateSecret:   secretsmanager.Interface.Generate,
+		},
+	}
+
+	for _, option := range options {
+		option(gcmx)
+	}
+
+	return gcmx
+}
+
+// gardenerCustomMetricsTestIsolation contains all points of indirection necessary to isolate static function calls
+// in the GardenerCustomMetrics unit during tests.
+type gardenerCustomMetricsTestIsolation struct {
+	// CreateForSeed points to managedresources.CreateForSeed
+	CreateForSeed func(ctx context.Context, client client.Client, namespace, name string, keepObjects bool, data map[string][]byte) error
+	// DeleteForSeed points to managedresources.DeleteForSeed
+	DeleteForSeed func(ctx context.Context, client client.Client, namespace, name string) error
+	// WaitUntilHealthy points to managedresources.WaitUntilHealthy
+	WaitUntilHealthy func(ctx context.Context, client client.Client, namespace, name string) error
+	// WaitUntilDeleted points to managedresources.WaitUntilDeleted
+	WaitUntilDeleted func(ctx context.Context, client client.Client, namespace, name string) error
+	// GenerateSecret points to the Generate method of the component's secrets manager. The fake secrets manager used
+	// by tests does not record its invocation parameters, so tests which need to verify them hook this seam instead.
+	GenerateSecret func(sm secretsmanager.Interface, ctx context.Context, config secretutils.ConfigInterface, opts ...secretsmanager.GenerateOption) (*corev1.Secret, error)
+}
+
+// Deploy implements component.Deployer. If the component is disabled, Deploy removes it from the seed instead.
+func (gcmx *GardenerCustomMetrics) Deploy(ctx context.Context) error {
+	baseErrorMessage := fmt.Sprintf(
+		"An error occurred while deploying gardener-custom-metrics component in namespace '%s' of the seed server",
+		gcmx.namespace)
+
+	if !gcmx.isEnabled {
+		gcmx.log.V(1).Info("Removing gardener-custom-metrics, since it is disabled", "namespace", gcmx.namespace)
+		if err := gcmx.Destroy(ctx); err != nil {
+			return fmt.Errorf(baseErrorMessage+
+				" - failed to bring the component to a disabled state. The error message reported by the underlying operation follows: %w",
+				err)
+		}
+		return nil
+	}
+
+	gcmx.log.V(1).Info("Deploying gardener-custom-metrics", "namespace", gcmx.namespace)
+
+	if err := component.CheckImageReference(gcmx.containerImageName, false); err != nil {
+		return fmt.Errorf(baseErrorMessage+
+			" - the specified container image is not usable. The error message reported by the underlying operation follows: %w",
+			err)
+	}
+
+	if gcmx.scrapeInterval != nil && *gcmx.scrapeInterval <= 0 {
+		return fmt.Errorf(baseErrorMessage+
+			" - the configured scrape interval '%s' is not a positive duration",
+			*gcmx.scrapeInterval)
+	}
+
+	serverCertificateSecret, err := gcmx.deployServerCertificate(ctx)
+	if err != nil {
+		return fmt.Errorf(baseErrorMessage+
+			" - failed to deploy the server TLS certificate to the seed server. The error message reported by the underlying operation follows: %w",
+			err)
+	}
+
+	resources, err := gcmx.computeResourcesData(serverCertificateSecret.Name)
+	if err != nil {
+		return fmt.Errorf(baseErrorMessage+
+			" - failed to compute the data of the ManagedResource which deploys the component. The error message reported by the underlying operation follows: %w",
+			err)
+	}
+
+	gcmx.log.V(1).Info("Deploying ManagedResource", "managedResource", managedResourceName)
+	if err := gcmx.createManagedResource(ctx, resources); err != nil {
+		return fmt.Errorf(baseErrorMessage+
+			" - failed to deploy the ManagedResource which deploys the component to the seed server. The error message reported by the underlying operation follows: %w",
+			err)
+	}
+
+	return nil
+}
+
+// createManagedResource creates (or updates) the component's ManagedResource in the seed. Transient apiserver
+// failures (conflict, server timeout) are retried a bounded number of times; all other failures abort immediately.
+func (gcmx *GardenerCustomMetrics) createManagedResource(ctx context.Context, resources map[string][]byte) error {
+	attempt := 0
+	return retry.Until(ctx, deployManagedResourceRetryInterval, func(ctx context.Context) (done bool, err error) {
+		attempt++
+		if err := gcmx.testIsolation.CreateForSeed(ctx, gcmx.seedClient, gcmx.namespace, managedResourceName, gcmx.keepObjects, resources); err != nil {
+			if apierrors.IsConflict(err) || apierrors.IsServerTimeout(err) {
+				return retry.MinorOrSevereError(attempt, deployManagedResourceMaxRetries, err)
+			}
+			return retry.SevereError(err)
+		}
+		return retry.Ok()
+	})
+}
+
+// DestroyPlan describes the seed artifacts which a Destroy call would remove.
+type DestroyPlan struct {
+	// ManagedResourceName is the name of the component's ManagedResource, whose deletion garbage-collects the
+	// component's k8s objects.
+	ManagedResourceName string
+	// ObjectRefs are the keys of the k8s objects which would be garbage-collected along with the ManagedResource.
+	ObjectRefs []client.ObjectKey
+}
+
+// PlanDestroy reports what Destroy would remove from the seed, without mutating it.
+func (gcmx *GardenerCustomMetrics) PlanDestroy() DestroyPlan {
+	return DestroyPlan{
+		ManagedResourceName: managedResourceName,
+		ObjectRefs:          ExpectedObjectRefs(gcmx.namespace),
+	}
+}
+
+// Destroy implements component.Deployer. In dry-run mode (see WithDryRun), it only logs the removals it would
+// perform, leaving the seed untouched.
+func (gcmx *GardenerCustomMetrics) Destroy(ctx context.Context) error {
+	baseErrorMessage := fmt.Sprintf(
+		"An error occurred while removing gardener-custom-metrics component in namespace '%s' of the seed server",
+		gcmx.namespace)
+
+	if gcmx.dryRun {
+		plan := gcmx.PlanDestroy()
+		gcmx.log.Info("Dry run: would delete ManagedResource and garbage-collect its objects",
+			"managedResource", plan.ManagedResourceName, "objectRefs", plan.ObjectRefs)
+		return nil
+	}
+
+	// Removing the ManagedResource deletes the custom metrics APIService along with it, which briefly makes the seed
+	// kube-apiserver return errors for any in-flight custom metrics query. Surface HPAs which would be affected.
+	consumers, err := gcmx.findCustomMetricConsumers(ctx)
+	if err != nil {
+		return fmt.Errorf(baseErrorMessage+
+			" - failed to determine whether an HPA in the seed still scales on the custom metric provided by the component. The error message reported by the underlying operation follows: %w",
+			err)
+	}
+	if len(consumers) > 0 {
+		if gcmx.strictDestroy {
+			return fmt.Errorf(baseErrorMessage+
+				" - the following HPAs still scale on the custom metric provided by the component: %v. Remove them first, or allow the removal to proceed regardless by disabling strict destroy",
+				consumers)
+		}
+		gcmx.log.Info("HPAs in the seed still scale on the custom metric provided by gardener-custom-metrics; they will lose their metric source",
+			"hpas", consumers)
+	}
+
+	gcmx.log.V(1).Info("Deleting ManagedResource", "managedResource", managedResourceName)
+	if err := gcmx.testIsolation.DeleteForSeed(ctx, gcmx.seedClient, gcmx.namespace, managedResourceName); err != nil {
+		return fmt.Errorf(baseErrorMessage+
+			" - failed to remove the ManagedResource which deploys the component. The error message reported by the underlying operation follows: %w",
+			err)
+	}
+
+	// The server TLS certificate secret is managed by the secrets manager, but said manager's cleanup only runs as
+	// part of a shoot reconciliation flow which generates secrets. Delete the secret explicitly, so that disabling
+	// the component leaves no residue.
+	gcmx.log.V(1).Info("Deleting server TLS certificate", "secret", serverCertificateSecretName)
+	serverCertificateSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: serverCertificateSecretName, Namespace: gcmx.namespace},
+	}
+	if err := kutil.DeleteObject(ctx, gcmx.seedClient, serverCertificateSecret); err != nil {
+		return fmt.Errorf(baseErrorMessage+
+			" - failed to remove the server TLS certificate secret '%s'. The error message reported by the underlying operation follows: %w",
+			serverCertificateSecretName,
+			err)
+	}
+
+	return nil
+}
+
+// findCustomMetricConsumers returns the keys of all HPAs in the seed which scale on the custom pods metric provided
+// by GCMx.
+func (gcmx *GardenerCustomMetrics) findCustomMetricConsumers(ctx context.Context) ([]client.ObjectKey, error) {
+	hpaList := &autoscalingv2beta2.HorizontalPodAutoscalerList{}
+	if err := gcmx.seedClient.List(ctx, hpaList); err != nil {
+		return nil, fmt.Errorf("failed to list the seed's HPAs: %w", err)
+	}
+
+	var consumers []client.ObjectKey
+	for _, hpa := range hpaList.Items {
+		for _, metric := range hpa.Spec.Metrics {
+			if metric.Type == autoscalingv2beta2.PodsMetricSourceType &&
+				metric.Pods != nil &&
+				metric.Pods.Metric.Name == APIServerRequestRateMetricName {
+
+				consumers = append(consumers, client.ObjectKeyFromObject(&hpa))
+				break
+			}
+		}
+	}
+
+	return consumers, nil
+}
+
+// Migrate implements component.Migrator. It removes the component's ManagedResource from the seed while instructing
+// the resource manager to keep the deployed objects in place, and preserves the server TLS certificate secret, so
+// that a subsequent Deploy in the target seed can restore the component without regenerating certificates.
+func (gcmx *GardenerCustomMetrics) Migrate(ctx context.Context) error {
+	baseErrorMessage := fmt.Sprintf(
+		"An error occurred while migrating gardener-custom-metrics component in namespace '%s' of the seed server",
+		gcmx.namespace)
+
+	gcmx.log.V(1).Info("Migrating: deleting ManagedResource, keeping deployed objects", "managedResource", managedResourceName)
+
+	if err := managedresources.SetKeepObjects(ctx, gcmx.seedClient, gcmx.namespace, managedResourceName, true); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf(baseErrorMessage+
+			" - failed to configure the component's ManagedResource to keep its objects. The error message reported by the underlying operation follows: %w",
+			err)
+	}
+
+	if err := gcmx.testIsolation.DeleteForSeed(ctx, gcmx.seedClient, gcmx.namespace, managedResourceName); err != nil {
+		return fmt.Errorf(baseErrorMessage+
+			" - failed to remove the ManagedResource which deploys the component. The error message reported by the underlying operation follows: %w",
+			err)
+	}
+
+	return nil
+}
+
+// DeployWait deploys the component and then waits for the deployment to fully take effect. It is a convenience
+// shortcut for calling Deploy followed by Wait, and fails upon the first error encountered.
+func (gcmx *GardenerCustomMetrics) DeployWait(ctx context.Context) error {
+	if err := gcmx.Deploy(ctx); err != nil {
+		return err
+	}
+
+	return gcmx.Wait(ctx)
+}
+
+// Restore implements component.Migrator. Restoring the component in the target seed requires no state from the
+// source seed - a plain Deploy recreates the ManagedResource and reuses the migrated server TLS certificate secret.
+func (gcmx *GardenerCustomMetrics) Restore(ctx context.Context, _ *gardencorev1alpha1.ShootState) error {
+	return gcmx.Deploy(ctx)
+}
+
+// Wait implements component.Waiter.
+func (gcmx *GardenerCustomMetrics) Wait(ctx context.Context) error {
+	if !gcmx.isEnabled {
+		return nil
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, gcmx.waitTimeout)
+	defer cancel()
+
+	if err := gcmx.waitForObservedGeneration(timeoutCtx); err != nil {
+		return fmt.Errorf(
+			"An error occurred while waiting for the gardener-resource-manager to observe the latest generation of the gardener-custom-metrics component's ManagedResource in namespace '%s' of the seed server: %w",
+			gcmx.namespace,
+			err)
+	}
+
+	if err := gcmx.testIsolation.WaitUntilHealthy(timeoutCtx, gcmx.seedClient, gcmx.namespace, managedResourceName); err != nil {
+		return fmt.Errorf(
+			"An error occurred while waiting for the gardener-custom-metrics component deployment process in namespace '%s' of the seed server to finish and for the component to report ready status: %w",
+			gcmx.namespace,
+			err)
+	}
+
+	gcmx.log.V(1).Info("ManagedResource is healthy", "managedResource", managedResourceName)
+	return nil
+}
+
+// waitForObservedGeneration polls the seed until the gardener-resource-manager reports that it has observed the
+// latest generation of the component's ManagedResource. The health evaluation performed by Wait is based on the
+// ManagedResource's status section, which right after a fresh Deploy may still reflect the object's previous state;
+// without this barrier, Wait could report the component healthy based on that stale status.
+func (gcmx *GardenerCustomMetrics) waitForObservedGeneration(ctx context.Context) error {
+	return retry.Until(ctx, waitForObservedGenerationRetryInterval, func(ctx context.Context) (done bool, err error) {
+		managedResource := &resourcesv1alpha1.ManagedResource{}
+		if err := gcmx.seedClient.Get(ctx, kutil.Key(gcmx.namespace, managedResourceName), managedResource); err != nil {
+			if apierrors.IsNotFound(err) {
+				return retry.MinorError(err)
+			}
+			return retry.SevereError(err)
+		}
+
+		if managedResource.Status.ObservedGeneration != managedResource.Generation {
+			return retry.MinorError(fmt.Errorf(
+				"the observed generation %d of the ManagedResource is behind its current generation %d",
+				managedResource.Status.ObservedGeneration,
+				managedResource.Generation))
+		}
+
+		return retry.Ok()
+	})
+}
+
+// WaitForAPIServiceAvailable polls the seed until the APIService through which GCMx serves the custom metrics API
+// reports an Available condition with status True. A healthy ManagedResource (see Wait) only means that the
+// APIService object has been applied; registering with the seed's aggregation layer can lag behind, during which time
+// custom metrics queries still fail. Callers which need a working custom metrics pipeline (rather than a completed
+// rollout) wait for this additional signal. The configured wait timeout applies - see WithWaitTimeout.
+func (gcmx *GardenerCustomMetrics) WaitForAPIServiceAvailable(ctx context.Context) error {
+	apiServiceName := gcmx.apiServiceVersion() + "." + apiServiceGroup
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, gcmx.waitTimeout)
+	defer cancel()
+
+	err := retry.Until(timeoutCtx, waitForAPIServiceRetryInterval, func(ctx context.Context) (done bool, err error) {
+		apiService := &apiregistrationv1.APIService{}
+		if err := gcmx.seedClient.Get(ctx, client.ObjectKey{Name: apiServiceName}, apiService); err != nil {
+			if apierrors.IsNotFound(err) {
+				return retry.MinorError(err)
+			}
+			return retry.SevereError(err)
+		}
+
+		for _, condition := range apiService.Status.Conditions {
+			if condition.Type == apiregistrationv1.Available && condition.Status == apiregistrationv1.ConditionTrue {
+				return retry.Ok()
+			}
+		}
+		return retry.MinorError(fmt.Errorf("the APIService does not report an Available condition with status True"))
+	})
+	if err != nil {
+		return fmt.Errorf(
+			"An error occurred while waiting for the custom metrics APIService '%s' served by the gardener-custom-metrics component to become available to the seed server's aggregation layer: %w",
+			apiServiceName,
+			err)
+	}
+
+	gcmx.log.V(1).Info("APIService is available", "apiService", apiServiceName)
+	return nil
+}
+
+// WaitCleanup implements component.Waiter.
+func (gcmx *GardenerCustomMetrics) WaitCleanup(ctx context.Context) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, gcmx.waitTimeout)
+	defer cancel()
+
+	if err := gcmx.testIsolation.WaitUntilDeleted(timeoutCtx, gcmx.seedClient, gcmx.namespace, managedResourceName); err != nil {
+		return fmt.Errorf(
+			"An error occurred while waiting for the gardener-custom-metrics component in namespace '%s' of the seed server to be fully removed: %w",
+			gcmx.namespace,
+			err)
+	}
+
+	gcmx.log.V(1).Info("ManagedResource is deleted", "managedResource", managedResourceName)
+	return nil
+}
+
+// deployServerCertificate generates and deploys the GCMx server TLS certificate to the seed, and returns the
+// resulting secret.
+func (gcmx *GardenerCustomMetrics) deployServerCertificate(ctx context.Context) (*corev1.Secret, error) {
+	const baseErrorMessage = "An error occurred while deploying server TLS certificate for the gardener-custom-metrics component"
+
+	gcmx.log.V(1).Info("Deploying server TLS certificate", "secret", serverCertificateSecretName)
+
+	_, found := gcmx.secretsManager.Get(gcmx.caSecretName)
+	if !found {
+		return nil, fmt.Errorf(baseErrorMessage+
+			" - the CA certificate, which is required to sign said server certificate, is missing. The CA certificate is expected in a secret named '%s': %w",
+			gcmx.caSecretName,
+			ErrCASecretMissing)
+	}
+
+	serverCertificateSecret, err := gcmx.testIsolation.GenerateSecret(
+		gcmx.secretsManager,
+		ctx,
+		&secretutils.CertificateSecretConfig{
+			Name:                        serverCertificateSecretName,
+			CommonName:                  componentBaseName,
+			DNSNames:                    gcmx.serverCertificateDNSNames(),
+			IPAddresses:                 gcmx.ipAddresses,
+			CertType:                    secretutils.ServerCert,
+			SkipPublishingCACertificate: true,
+		},
+		secretsmanager.SignedByCA(gcmx.caSecretName, secretsmanager.UseCurrentCA),
+		secretsmanager.Rotate(secretsmanager.InPlace))
+	if err != nil {
+		return nil, fmt.Errorf(baseErrorMessage+
+			" - failed to generate the certificate and store it in a secret named '%s'. The error message reported by the underlying operation follows: %w",
+			serverCertificateSecretName,
+			err)
+	}
+
+	certificatePEM, ok := serverCertificateSecret.Data[secretutils.DataKeyCertificate]
+	if !ok {
+		// Certificates which are not signed by a separate CA are stored under a different data key
+		certificatePEM = serverCertificateSecret.Data[secretutils.DataKeyCertificateCA]
+	}
+	certificate, err := utils.DecodeCertificate(certificatePEM)
+	if err != nil {
+		return nil, fmt.Errorf(baseErrorMessage+
+			" - failed to parse the generated certificate stored in the secret named '%s'. The error message reported by the underlying operation follows: %w",
+			serverCertificateSecret.Name,
+			err)
+	}
+	expiry := certificate.NotAfter
+	gcmx.lastDeployedCertExpiry = &expiry
+
+	return serverCertificateSecret, nil
+}
+
+// serverCertificateDNSNames returns the DNS names covered by GCMx's server TLS certificate: the names derived from
+// the component's service, extended by the configured additional names (see WithAdditionalDNSNames), without
+// duplicates.
+func (gcmx *GardenerCustomMetrics) serverCertificateDNSNames() []string {
+	dnsNames := kutil.DNSNamesForService(serviceName, gcmx.namespace)
+
+	seen := map[string]struct{}{}
+	for _, name := range dnsNames {
+		seen[name] = struct{}{}
+	}
+	for _, name := range gcmx.additionalDNSNames {
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		dnsNames = append(dnsNames, name)
+	}
+
+	return dnsNames
+}
+
+// LastDeployedCertExpiry returns the expiration time of the server TLS certificate generated by the most recent
+// Deploy call on this instance, or nil if no certificate has been deployed yet. Operators use it to plan certificate
+// rotation.
+func (gcmx *GardenerCustomMetrics) LastDeployedCertExpiry() *time.Time {
+	return gcmx.lastDeployedCertExpiry
+}
+
+// sortedResourceKeys returns the keys of a ManagedResource data map in lexicographic order. The keys themselves are
+// deterministic - each is a pure function of the respective object's kind, namespace and name - but the map's
+// iteration order is not. Any consumer which enumerates the map and requires a stable order (e.g. to hash or diff the
+// output) is expected to walk the keys in the order defined here.
+func sortedResourceKeys(resources map[string][]byte) []string {
+	keys := make([]string, 0, len(resources))
+	for key := range resources {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// computeResourcesData serializes all k8s objects which comprise the component into a ManagedResource data map. The
+// map's keys, and the bytes serialized under them, are deterministic - successive calls over the same configuration
+// produce equal maps.
+func (gcmx *GardenerCustomMetrics) computeResourcesData(serverCertificateSecretName string) (map[string][]byte, error) {
+	caSecret, found := gcmx.secretsManager.Get(gcmx.caSecretName)
+	if !found {
+		return nil, fmt.Errorf("the CA certificate secret '%s' does not exist: %w", gcmx.caSecretName, ErrCASecretMissing)
+	}
+
+	params := defaultKubeObjectsParameters(gcmx.namespace)
+	params.ContainerImageName = gcmx.containerImageName
+	params.ServerCertificateSecretName = serverCertificateSecretName
+	params.CABundle = caSecret.Data[secretutils.DataKeyCertificateBundle]
+	params.APIServiceVersion = gcmx.apiServiceVersion()
+	params.Replicas = gcmx.replicas
+	params.NodeSelector = gcmx.nodeSelector
+	params.Tolerations = gcmx.tolerations
+	params.Affinity = gcmx.affinity
+	params.TopologySpreadConstraints = gcmx.topologySpreadConstraints
+	if gcmx.livenessProbePath != "" {
+		params.LivenessProbePath = gcmx.livenessProbePath
+	}
+	if gcmx.readinessProbePath != "" {
+		params.ReadinessProbePath = gcmx.readinessProbePath
+	}
+	if gcmx.probePort != 0 {
+		params.ProbePort = gcmx.probePort
+	}
+	if params.ProbePort == params.AccessPort {
+		return nil, fmt.Errorf(
+			"the configured probe port %d collides with the port of the secure custom metrics endpoint", params.ProbePort)
+	}
+	if gcmx.containerName != "" {
+		params.ContainerName = gcmx.containerName
+	}
+	params.TerminationGracePeriodSeconds = gcmx.terminationGracePeriodSeconds
+	params.PreStopSleepSeconds = gcmx.preStopSleepSeconds
+	if gcmx.revisionHistoryLimit != nil {
+		params.RevisionHistoryLimit = gcmx.revisionHistoryLimit
+	}
+	params.DeploymentStrategy = gcmx.deploymentStrategy
+	params.ResourceLimits = gcmx.resourceLimits
+	if gcmx.scrapeInterval != nil {
+		params.ScrapeInterval = *gcmx.scrapeInterval
+	}
+	if gcmx.serviceSessionAffinity != "" {
+		params.ServiceSessionAffinity = gcmx.serviceSessionAffinity
+	}
+	params.ServicePublishNotReadyAddresses = gcmx.servicePublishNotReadyAddresses
+	params.ShootAPIServerEgressCIDRs = gcmx.shootAPIServerEgressCIDRs
+	params.ExtraEnv = gcmx.extraEnv
+	params.ExtraVolumes = gcmx.extraVolumes
+	params.ExtraVolumeMounts = gcmx.extraVolumeMounts
+	params.RestrictedSecretNames = gcmx.restrictedSecretNames
+	params.RegisterAPIService = gcmx.registerAPIService
+
+	registry := managedresources.NewRegistry(kubernetes.SeedScheme, kubernetes.SeedCodec, kubernetes.SeedSerializer)
+
+	if !gcmx.registerAPIService {
+		// The ServiceMonitor is unstructured, which the typed object registry cannot serialize
+		serviceMonitorYAML, err := yaml.Marshal(makeServiceMonitor(gcmx.namespace).Object)
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize the ServiceMonitor: %w", err)
+		}
+		registry.AddSerialized(fmt.Sprintf("servicemonitor__%s__%s.yaml", gcmx.namespace, componentBaseName), serviceMonitorYAML)
+	}
+
+	return registry.AddAllAndSerialize(makeKubeObjects(params)...)
+}
+
+// GetKubeObjectsAsMultiDocYAML renders all k8s objects which comprise the component into a single multi-document
+// YAML stream, with the documents separated by '---' markers and arranged in the lexicographic order of the objects'
+// identity keys. Unlike the per-object data map behind the component's ManagedResource, whose iteration order is
+// unspecified, this concatenated form is deterministic, which makes it suitable for external tooling that diffs or
+// hashes the component's manifests (e.g. GitOps pipelines).
+func (gcmx *GardenerCustomMetrics) GetKubeObjectsAsMultiDocYAML() ([]byte, error) {
+	resources, err := gcmx.computeResourcesData(serverCertificateSecretName)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"An error occurred while rendering the k8s objects of the gardener-custom-metrics component as a multi-document YAML stream: %w",
+			err)
+	}
+
+	var stream bytes.Buffer
+	for _, key := range sortedResourceKeys(resources) {
+		stream.WriteString("---\n")
+		stream.Write(resources[key])
+	}
+
+	return stream.Bytes(), nil
+}
+
+// apiServiceVersion returns the version of the custom metrics API which GCMx provides to the respective seed
+// kube-apiserver. Old seed versions, which predate the v1beta2 custom metrics API, are served the v1beta1 flavor.
+func (gcmx *GardenerCustomMetrics) apiServiceVersion() string {
+	if gcmx.kubernetesVersion != nil && !versionutils.ConstraintK8sGreaterEqual121.Check(gcmx.kubernetesVersion) {
+		return "v1beta1"
+	}
+	return defaultAPIServiceVersion
+}
+
+// ensure that GardenerCustomMetrics implements the necessary component interfaces
+var (
+	_ component.DeployWaiter = (*GardenerCustomMetrics)(nil)
+	_ component.Migrator     = (*GardenerCustomMetrics)(nil)
+)
@@ -0,0 +1,800 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gardenercustommetrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	gardencorev1beta1 "github.com/gardener/gardener/pkg/apis/core/v1beta1"
+	resourcesv1alpha1 "github.com/gardener/gardener/pkg/apis/resources/v1alpha1"
+	"github.com/gardener/gardener/pkg/client/kubernetes"
+	kutil "github.com/gardener/gardener/pkg/utils/kubernetes"
+	secretutils "github.com/gardener/gardener/pkg/utils/secrets"
+	secretsmanager "github.com/gardener/gardener/pkg/utils/secrets/manager"
+	fakesecretsmanager "github.com/gardener/gardener/pkg/utils/secrets/manager/fake"
+	. "github.com/gardener/gardener/pkg/utils/test/matchers"
+
+	"github.com/Masterminds/semver"
+	"github.com/go-logr/logr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gstruct"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	apiregistrationv1 "k8s.io/kube-aggregator/pkg/apis/apiregistration/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("GardenerCustomMetrics", func() {
+	const (
+		namespace          = "shoot--foo--bar"
+		containerImageName = "test-image:test-version"
+	)
+
+	var (
+		ctx        context.Context
+		seedClient client.Client
+		sm         secretsmanager.Interface
+		gcmx       *GardenerCustomMetrics
+
+		// The parameters of the last recorded call to the CreateForSeed test isolation point
+		createForSeedNamespace   string
+		createForSeedName        string
+		createForSeedKeepObjects bool
+		createForSeedData        map[string][]byte
+		createForSeedCallCount   int
+
+		// The parameters of the last recorded call to the DeleteForSeed test isolation point
+		deleteForSeedNamespace string
+		deleteForSeedName      string
+		deleteForSeedCallCount int
+
+		// The messages recorded by the log sink injected into the tested instance
+		logMessages []string
+	)
+
+	// Creates a GardenerCustomMetrics instance where the calls to the managedresources package are replaced by
+	// recording stubs.
+	newGcmxWithKeepObjects := func(isEnabled, keepObjects bool) *GardenerCustomMetrics {
+		result := NewGardenerCustomMetrics(
+			namespace, containerImageName, seedClient, sm,
+			WithEnabled(isEnabled),
+			WithKeepObjects(keepObjects),
+			WithKubernetesVersion(semver.MustParse("1.26.1")),
+			WithLogger(logr.New(&recordingLogSink{messages: &logMessages})))
+
+		result.testIsolation.CreateForSeed = func(
+			_ context.Context, _ client.Client, namespace, name string, keepObjects bool, data map[string][]byte) error {
+
+			createForSeedNamespace = namespace
+			createForSeedName = name
+			createForSeedKeepObjects = keepObjects
+			createForSeedData = data
+			createForSeedCallCount++
+			return nil
+		}
+		result.testIsolation.DeleteForSeed = func(_ context.Context, _ client.Client, namespace, name string) error {
+			deleteForSeedNamespace = namespace
+			deleteForSeedName = name
+			deleteForSeedCallCount++
+			return nil
+		}
+
+		return result
+	}
+
+	newGcmx := func(isEnabled bool) *GardenerCustomMetrics {
+		return newGcmxWithKeepObjects(isEnabled, false)
+	}
+
+	BeforeEach(func() {
+		ctx = context.TODO()
+		seedClient = fakeclient.NewClientBuilder().WithScheme(kubernetes.SeedScheme).Build()
+		sm = fakesecretsmanager.New(seedClient, namespace)
+
+		// Speed up the retry loop around ManagedResource creation
+		originalRetryInterval := deployManagedResourceRetryInterval
+		deployManagedResourceRetryInterval = 1 * time.Millisecond
+		DeferCleanup(func() {
+			deployManagedResourceRetryInterval = originalRetryInterval
+		})
+		createForSeedCallCount = 0
+		deleteForSeedCallCount = 0
+		logMessages = nil
+		gcmx = newGcmx(true)
+	})
+
+	Describe("#NewGardenerCustomMetrics", func() {
+		It("should apply the specified options", func() {
+			nodeSelector := map[string]string{"worker.gardener.cloud/pool": "monitoring"}
+			tolerations := []corev1.Toleration{{Key: "dedicated", Effect: corev1.TaintEffectNoSchedule}}
+
+			gcmx = NewGardenerCustomMetrics(
+				namespace, containerImageName, seedClient, sm,
+				WithNodeSelector(nodeSelector), WithTolerations(tolerations))
+
+			Expect(gcmx.nodeSelector).To(Equal(nodeSelector))
+			Expect(gcmx.tolerations).To(Equal(tolerations))
+		})
+
+		It("should apply the documented defaults when no options are given", func() {
+			gcmx = NewGardenerCustomMetrics(namespace, containerImageName, seedClient, sm)
+
+			Expect(gcmx.isEnabled).To(BeTrue())
+			Expect(gcmx.keepObjects).To(BeFalse())
+			Expect(gcmx.kubernetesVersion).To(BeNil())
+			Expect(gcmx.replicas).To(Equal(int32(1)))
+		})
+	})
+
+	Describe("#Deploy", func() {
+		It("should deploy a ManagedResource with the expected parameters", func() {
+			Expect(gcmx.Deploy(ctx)).To(Succeed())
+
+			Expect(createForSeedCallCount).To(Equal(1))
+			Expect(createForSeedNamespace).To(Equal(namespace))
+			Expect(createForSeedName).To(Equal(managedResourceName))
+			Expect(createForSeedKeepObjects).To(BeFalse())
+			Expect(createForSeedData).NotTo(BeEmpty())
+		})
+
+		It("should deploy the server TLS certificate to the seed", func() {
+			Expect(gcmx.Deploy(ctx)).To(Succeed())
+
+			secretList := &corev1.SecretList{}
+			Expect(seedClient.List(ctx, secretList, client.InNamespace(namespace))).To(Succeed())
+
+			isServerCertificateDeployed := false
+			for _, secret := range secretList.Items {
+				if strings.HasPrefix(secret.Name, serverCertificateSecretName) {
+					isServerCertificateDeployed = true
+				}
+			}
+			Expect(isServerCertificateDeployed).To(BeTrue())
+		})
+
+		It("should request a server certificate with the intended parameters", func() {
+			var capturedConfig secretutils.ConfigInterface
+			gcmx.testIsolation.GenerateSecret = func(
+				sm secretsmanager.Interface,
+				ctx context.Context,
+				config secretutils.ConfigInterface,
+				opts ...secretsmanager.GenerateOption,
+			) (*corev1.Secret, error) {
+				capturedConfig = config
+				return sm.Generate(ctx, config, opts...)
+			}
+
+			Expect(gcmx.Deploy(ctx)).To(Succeed())
+
+			certificateConfig, isCertificateConfig := capturedConfig.(*secretutils.CertificateSecretConfig)
+			Expect(isCertificateConfig).To(BeTrue())
+			Expect(certificateConfig.Name).To(Equal(serverCertificateSecretName))
+			Expect(certificateConfig.CommonName).To(Equal("gardener-custom-metrics"))
+			Expect(certificateConfig.CertType).To(Equal(secretutils.ServerCert))
+			Expect(certificateConfig.DNSNames).To(ContainElements(
+				"gardener-custom-metrics",
+				"gardener-custom-metrics."+namespace,
+				"gardener-custom-metrics."+namespace+".svc",
+			))
+		})
+
+		It("should cover configured additional DNS names with the server certificate, without duplicates", func() {
+			var capturedConfig secretutils.ConfigInterface
+			gcmx = NewGardenerCustomMetrics(
+				namespace, containerImageName, seedClient, sm,
+				WithAdditionalDNSNames([]string{"gcmx.internal.example.com", "gardener-custom-metrics"}))
+			gcmx.testIsolation.GenerateSecret = func(
+				sm secretsmanager.Interface,
+				ctx context.Context,
+				config secretutils.ConfigInterface,
+				opts ...secretsmanager.GenerateOption,
+			) (*corev1.Secret, error) {
+				capturedConfig = config
+				return sm.Generate(ctx, config, opts...)
+			}
+
+			Expect(gcmx.Deploy(ctx)).To(Succeed())
+
+			certificateConfig, isCertificateConfig := capturedConfig.(*secretutils.CertificateSecretConfig)
+			Expect(isCertificateConfig).To(BeTrue())
+			Expect(certificateConfig.DNSNames).To(ContainElement("gcmx.internal.example.com"))
+			Expect(certificateConfig.DNSNames).To(HaveLen(len(kutil.DNSNamesForService("gardener-custom-metrics", namespace)) + 1))
+		})
+
+		It("should cover configured IP addresses with the server certificate", func() {
+			var capturedConfig secretutils.ConfigInterface
+			ipAddresses := []net.IP{net.ParseIP("10.0.0.7"), net.ParseIP("fd00::7")}
+			gcmx = NewGardenerCustomMetrics(
+				namespace, containerImageName, seedClient, sm,
+				WithIPAddresses(ipAddresses))
+			gcmx.testIsolation.GenerateSecret = func(
+				sm secretsmanager.Interface,
+				ctx context.Context,
+				config secretutils.ConfigInterface,
+				opts ...secretsmanager.GenerateOption,
+			) (*corev1.Secret, error) {
+				capturedConfig = config
+				return sm.Generate(ctx, config, opts...)
+			}
+
+			Expect(gcmx.Deploy(ctx)).To(Succeed())
+
+			certificateConfig, isCertificateConfig := capturedConfig.(*secretutils.CertificateSecretConfig)
+			Expect(isCertificateConfig).To(BeTrue())
+			Expect(certificateConfig.IPAddresses).To(Equal(ipAddresses))
+		})
+
+		It("should deploy an APIService which matches the Kubernetes version of the seed", func() {
+			Expect(gcmx.Deploy(ctx)).To(Succeed())
+
+			Expect(createForSeedData).To(HaveKey(
+				fmt.Sprintf("apiservice____v1beta2.%s.yaml", apiServiceGroup)))
+		})
+
+		It("should deploy the aggregation RBAC bindings and no ServiceMonitor by default", func() {
+			Expect(gcmx.Deploy(ctx)).To(Succeed())
+
+			Expect(createForSeedData).To(HaveKey("clusterrolebinding____gardener-custom-metrics_system_auth-delegator.yaml"))
+			Expect(createForSeedData).To(HaveKey("rolebinding__kube-system__gardener-custom-metrics-auth-reader.yaml"))
+			Expect(createForSeedData).NotTo(HaveKey(HavePrefix("servicemonitor__")))
+		})
+
+		It("should deploy a ServiceMonitor instead of the APIService and aggregation RBAC in push mode", func() {
+			WithAPIServiceRegistration(false)(gcmx)
+
+			Expect(gcmx.Deploy(ctx)).To(Succeed())
+
+			Expect(createForSeedData).To(HaveKey("servicemonitor__" + namespace + "__gardener-custom-metrics.yaml"))
+			Expect(createForSeedData).NotTo(HaveKey(HavePrefix("apiservice__")))
+			Expect(createForSeedData).NotTo(HaveKey("clusterrolebinding____gardener-custom-metrics_system_auth-delegator.yaml"))
+			Expect(createForSeedData).NotTo(HaveKey("rolebinding__kube-system__gardener-custom-metrics-auth-reader.yaml"))
+
+			serviceMonitorYaml := string(createForSeedData["servicemonitor__"+namespace+"__gardener-custom-metrics.yaml"])
+			Expect(serviceMonitorYaml).To(ContainSubstring("kind: ServiceMonitor"))
+			Expect(serviceMonitorYaml).To(ContainSubstring("port: " + servicePortName))
+		})
+
+		It("should deploy a v1beta1 APIService if the seed predates the v1beta2 custom metrics API", func() {
+			gcmx = newGcmx(true)
+			gcmx.kubernetesVersion = semver.MustParse("1.20.11")
+
+			Expect(gcmx.Deploy(ctx)).To(Succeed())
+
+			Expect(createForSeedData).To(HaveKey(
+				fmt.Sprintf("apiservice____v1beta1.%s.yaml", apiServiceGroup)))
+		})
+
+		It("should refuse to deploy an empty container image reference", func() {
+			gcmx.containerImageName = ""
+
+			Expect(gcmx.Deploy(ctx)).To(MatchError(ContainSubstring("image")))
+			Expect(createForSeedCallCount).To(Equal(0))
+		})
+
+		It("should refuse to deploy an untagged container image reference", func() {
+			gcmx.containerImageName = "registry.example.com/test-image"
+
+			Expect(gcmx.Deploy(ctx)).To(MatchError(ContainSubstring("neither a tag, nor a digest")))
+			Expect(createForSeedCallCount).To(Equal(0))
+		})
+
+		It("should sign the server certificate with a configured custom CA", func() {
+			gcmx.secretsManager = &limitedSecretsManager{Interface: sm, knownName: "ca-extensions"}
+			WithCASecretName("ca-extensions")(gcmx)
+
+			Expect(gcmx.Deploy(ctx)).To(Succeed())
+
+			Expect(createForSeedCallCount).To(Equal(1))
+		})
+
+		It("should name the configured CA secret if it is missing", func() {
+			gcmx.secretsManager = &limitedSecretsManager{Interface: sm, knownName: "some-other-secret"}
+			WithCASecretName("ca-extensions")(gcmx)
+
+			err := gcmx.Deploy(ctx)
+
+			Expect(errors.Is(err, ErrCASecretMissing)).To(BeTrue())
+			Expect(err).To(MatchError(ContainSubstring("CA certificate")))
+			Expect(err).To(MatchError(ContainSubstring("ca-extensions")))
+			Expect(createForSeedCallCount).To(Equal(0))
+		})
+
+		It("should refuse to deploy a probe port which collides with the secure custom metrics port", func() {
+			WithProbeEndpoints("", "", containerPort)(gcmx)
+
+			Expect(gcmx.Deploy(ctx)).To(MatchError(ContainSubstring("collides")))
+		})
+
+		It("should refuse to deploy a non-positive scrape interval", func() {
+			WithScrapeInterval(-30 * time.Second)(gcmx)
+
+			Expect(gcmx.Deploy(ctx)).To(MatchError(ContainSubstring("scrape interval")))
+			Expect(createForSeedCallCount).To(Equal(0))
+		})
+
+		It("should render a configured scrape interval into the deployed command line", func() {
+			WithScrapeInterval(30 * time.Second)(gcmx)
+
+			Expect(gcmx.Deploy(ctx)).To(Succeed())
+
+			deploymentYaml := string(createForSeedData["deployment__"+namespace+"__gardener-custom-metrics.yaml"])
+			Expect(deploymentYaml).To(ContainSubstring("--scrape-interval=30s"))
+		})
+
+		It("should surface the expiry of the generated server certificate", func() {
+			Expect(gcmx.LastDeployedCertExpiry()).To(BeNil())
+
+			Expect(gcmx.Deploy(ctx)).To(Succeed())
+
+			expiry := gcmx.LastDeployedCertExpiry()
+			Expect(expiry).NotTo(BeNil())
+			Expect(expiry.After(time.Now())).To(BeTrue())
+		})
+
+		It("should propagate the keepObjects flag to the ManagedResource", func() {
+			gcmx = newGcmxWithKeepObjects(true, true)
+
+			Expect(gcmx.Deploy(ctx)).To(Succeed())
+
+			Expect(createForSeedCallCount).To(Equal(1))
+			Expect(createForSeedKeepObjects).To(BeTrue())
+		})
+
+		It("should retry creating the ManagedResource upon transient failures", func() {
+			failuresLeft := 2
+			innerCreateForSeed := gcmx.testIsolation.CreateForSeed
+			gcmx.testIsolation.CreateForSeed = func(
+				ctx context.Context, c client.Client, namespace, name string, keepObjects bool, data map[string][]byte) error {
+
+				if failuresLeft > 0 {
+					failuresLeft--
+					return apierrors.NewConflict(schema.GroupResource{}, managedResourceName, fmt.Errorf("fake conflict"))
+				}
+				return innerCreateForSeed(ctx, c, namespace, name, keepObjects, data)
+			}
+
+			Expect(gcmx.Deploy(ctx)).To(Succeed())
+
+			Expect(failuresLeft).To(Equal(0))
+			Expect(createForSeedCallCount).To(Equal(1))
+		})
+
+		It("should give up creating the ManagedResource after a bounded number of attempts", func() {
+			attemptCount := 0
+			gcmx.testIsolation.CreateForSeed = func(
+				_ context.Context, _ client.Client, _, _ string, _ bool, _ map[string][]byte) error {
+
+				attemptCount++
+				return apierrors.NewConflict(schema.GroupResource{}, managedResourceName, fmt.Errorf("fake conflict"))
+			}
+
+			Expect(gcmx.Deploy(ctx)).NotTo(Succeed())
+			Expect(attemptCount).To(Equal(deployManagedResourceMaxRetries + 1))
+		})
+
+		It("should not retry creating the ManagedResource upon non-transient failures", func() {
+			attemptCount := 0
+			gcmx.testIsolation.CreateForSeed = func(
+				_ context.Context, _ client.Client, _, _ string, _ bool, _ map[string][]byte) error {
+
+				attemptCount++
+				return fmt.Errorf("fake permanent failure")
+			}
+
+			Expect(gcmx.Deploy(ctx)).NotTo(Succeed())
+			Expect(attemptCount).To(Equal(1))
+		})
+
+		It("should log the key steps of a successful deployment", func() {
+			Expect(gcmx.Deploy(ctx)).To(Succeed())
+
+			Expect(logMessages).To(ContainElements(
+				"Deploying gardener-custom-metrics",
+				"Deploying server TLS certificate",
+				"Deploying ManagedResource",
+			))
+		})
+
+		It("should remove the component if it is disabled", func() {
+			gcmx = newGcmx(false)
+
+			Expect(gcmx.Deploy(ctx)).To(Succeed())
+
+			Expect(createForSeedCallCount).To(Equal(0))
+			Expect(deleteForSeedCallCount).To(Equal(1))
+			Expect(deleteForSeedNamespace).To(Equal(namespace))
+			Expect(deleteForSeedName).To(Equal(managedResourceName))
+		})
+
+		It("should not leave the server TLS certificate secret behind when the component is disabled", func() {
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: serverCertificateSecretName, Namespace: namespace},
+			}
+			Expect(seedClient.Create(ctx, secret)).To(Succeed())
+			gcmx = newGcmx(false)
+
+			Expect(gcmx.Deploy(ctx)).To(Succeed())
+
+			Expect(deleteForSeedCallCount).To(Equal(1))
+			err := seedClient.Get(ctx, kutil.Key(namespace, serverCertificateSecretName), &corev1.Secret{})
+			Expect(err).To(BeNotFoundError())
+		})
+	})
+
+	Describe("#computeResourcesData", func() {
+		It("should produce byte-identical data maps with identical key sets across calls", func() {
+			first, err := gcmx.computeResourcesData(serverCertificateSecretName)
+			Expect(err).NotTo(HaveOccurred())
+
+			second, err := gcmx.computeResourcesData(serverCertificateSecretName)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(sortedResourceKeys(second)).To(Equal(sortedResourceKeys(first)))
+			Expect(second).To(Equal(first))
+		})
+	})
+
+	Describe("#GetKubeObjectsAsMultiDocYAML", func() {
+		It("should render all component objects as one multi-document YAML stream in a stable order", func() {
+			stream, err := gcmx.GetKubeObjectsAsMultiDocYAML()
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(stream)).To(HavePrefix("---\n"))
+			documents := strings.Split(string(stream), "---\n")[1:]
+			Expect(documents).To(HaveLen(len(ExpectedObjectRefs(namespace))))
+
+			// The documents follow the lexicographic order of the objects' identity keys
+			Expect(documents[0]).To(ContainSubstring("kind: APIService"))
+			Expect(documents[len(documents)-1]).To(ContainSubstring("kind: ServiceAccount"))
+		})
+
+		It("should produce byte-identical output across calls", func() {
+			stream, err := gcmx.GetKubeObjectsAsMultiDocYAML()
+			Expect(err).NotTo(HaveOccurred())
+
+			secondStream, err := gcmx.GetKubeObjectsAsMultiDocYAML()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(secondStream).To(Equal(stream))
+		})
+	})
+
+	Describe("#Destroy", func() {
+		It("should delete the ManagedResource", func() {
+			Expect(gcmx.Destroy(ctx)).To(Succeed())
+
+			Expect(deleteForSeedCallCount).To(Equal(1))
+			Expect(deleteForSeedNamespace).To(Equal(namespace))
+			Expect(deleteForSeedName).To(Equal(managedResourceName))
+		})
+
+		It("should delete the server TLS certificate secret", func() {
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: serverCertificateSecretName, Namespace: namespace},
+			}
+			Expect(seedClient.Create(ctx, secret)).To(Succeed())
+
+			Expect(gcmx.Destroy(ctx)).To(Succeed())
+
+			err := seedClient.Get(ctx, kutil.Key(namespace, serverCertificateSecretName), &corev1.Secret{})
+			Expect(err).To(BeNotFoundError())
+		})
+
+		It("should succeed if the server TLS certificate secret is missing", func() {
+			Expect(gcmx.Destroy(ctx)).To(Succeed())
+		})
+
+		// Creates an HPA in the seed which scales on the custom pods metric provided by GCMx.
+		createConsumingHpa := func() {
+			targetAverageValue := resource.MustParse("300")
+			hpa := &autoscalingv2beta2.HorizontalPodAutoscaler{
+				ObjectMeta: metav1.ObjectMeta{Name: "kube-apiserver-bipa", Namespace: "shoot--consumer--test"},
+				Spec: autoscalingv2beta2.HorizontalPodAutoscalerSpec{
+					MaxReplicas: 4,
+					ScaleTargetRef: autoscalingv2beta2.CrossVersionObjectReference{
+						APIVersion: "apps/v1", Kind: "Deployment", Name: "kube-apiserver",
+					},
+					Metrics: []autoscalingv2beta2.MetricSpec{{
+						Type: autoscalingv2beta2.PodsMetricSourceType,
+						Pods: &autoscalingv2beta2.PodsMetricSource{
+							Metric: autoscalingv2beta2.MetricIdentifier{Name: APIServerRequestRateMetricName},
+							Target: autoscalingv2beta2.MetricTarget{
+								Type:         autoscalingv2beta2.AverageValueMetricType,
+								AverageValue: &targetAverageValue,
+							},
+						},
+					}},
+				},
+			}
+			Expect(seedClient.Create(ctx, hpa)).To(Succeed())
+		}
+
+		It("should warn about HPAs which still scale on the custom metric, but proceed by default", func() {
+			createConsumingHpa()
+
+			Expect(gcmx.Destroy(ctx)).To(Succeed())
+
+			Expect(deleteForSeedCallCount).To(Equal(1))
+			Expect(logMessages).To(ContainElement(ContainSubstring("still scale on the custom metric")))
+		})
+
+		It("should refuse to remove the component in strict mode while an HPA still scales on the custom metric", func() {
+			WithStrictDestroy(true)(gcmx)
+			createConsumingHpa()
+
+			err := gcmx.Destroy(ctx)
+
+			Expect(err).To(MatchError(ContainSubstring("still scale on the custom metric")))
+			Expect(err).To(MatchError(ContainSubstring("kube-apiserver-bipa")))
+			Expect(deleteForSeedCallCount).To(Equal(0))
+		})
+
+		It("should remove the component in strict mode if no HPA scales on the custom metric", func() {
+			WithStrictDestroy(true)(gcmx)
+
+			Expect(gcmx.Destroy(ctx)).To(Succeed())
+
+			Expect(deleteForSeedCallCount).To(Equal(1))
+			Expect(logMessages).NotTo(ContainElement(ContainSubstring("still scale on the custom metric")))
+		})
+
+		It("should not mutate the seed in dry-run mode", func() {
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: serverCertificateSecretName, Namespace: namespace},
+			}
+			Expect(seedClient.Create(ctx, secret)).To(Succeed())
+			gcmx = newGcmx(true)
+			WithDryRun(true)(gcmx)
+
+			Expect(gcmx.Destroy(ctx)).To(Succeed())
+
+			Expect(deleteForSeedCallCount).To(Equal(0))
+			Expect(seedClient.Get(ctx, kutil.Key(namespace, serverCertificateSecretName), &corev1.Secret{})).
+				To(Succeed())
+		})
+	})
+
+	Describe("#PlanDestroy", func() {
+		It("should report the ManagedResource name and the object refs which would be garbage-collected", func() {
+			plan := gcmx.PlanDestroy()
+
+			Expect(plan.ManagedResourceName).To(Equal(managedResourceName))
+			Expect(plan.ObjectRefs).To(Equal(ExpectedObjectRefs(namespace)))
+		})
+	})
+
+	Describe("#DeployWait", func() {
+		It("should deploy the component and wait for the ManagedResource to become healthy", func() {
+			managedResource := &resourcesv1alpha1.ManagedResource{
+				ObjectMeta: metav1.ObjectMeta{Name: managedResourceName, Namespace: namespace, Generation: 1},
+				Status: resourcesv1alpha1.ManagedResourceStatus{
+					ObservedGeneration: 1,
+					Conditions: []gardencorev1beta1.Condition{
+						{Type: resourcesv1alpha1.ResourcesApplied, Status: gardencorev1beta1.ConditionTrue},
+						{Type: resourcesv1alpha1.ResourcesHealthy, Status: gardencorev1beta1.ConditionTrue},
+					},
+				},
+			}
+			Expect(seedClient.Create(ctx, managedResource)).To(Succeed())
+
+			Expect(gcmx.DeployWait(ctx)).To(Succeed())
+
+			Expect(createForSeedCallCount).To(Equal(1))
+		})
+
+		It("should short-circuit the wait if the component is disabled", func() {
+			gcmx = newGcmx(false)
+
+			Expect(gcmx.DeployWait(ctx)).To(Succeed())
+
+			Expect(createForSeedCallCount).To(Equal(0))
+			Expect(deleteForSeedCallCount).To(Equal(1))
+		})
+	})
+
+	Describe("#Wait", func() {
+		createManagedResource := func(generation, observedGeneration int64) {
+			managedResource := &resourcesv1alpha1.ManagedResource{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "gardener-custom-metrics",
+					Namespace:  namespace,
+					Generation: generation,
+				},
+				Status: resourcesv1alpha1.ManagedResourceStatus{ObservedGeneration: observedGeneration},
+			}
+			Expect(seedClient.Create(ctx, managedResource)).To(Succeed())
+		}
+
+		BeforeEach(func() {
+			originalRetryInterval := waitForObservedGenerationRetryInterval
+			waitForObservedGenerationRetryInterval = 1 * time.Millisecond
+			DeferCleanup(func() {
+				waitForObservedGenerationRetryInterval = originalRetryInterval
+			})
+		})
+
+		It("should honor the configured wait timeout", func() {
+			createManagedResource(2, 2)
+
+			var deadline time.Time
+			WithWaitTimeout(42 * time.Minute)(gcmx)
+			gcmx.testIsolation.WaitUntilHealthy = func(waitCtx context.Context, _ client.Client, _, _ string) error {
+				deadline, _ = waitCtx.Deadline()
+				return nil
+			}
+
+			before := time.Now()
+			Expect(gcmx.Wait(ctx)).To(Succeed())
+
+			Expect(deadline).To(BeTemporally("~", before.Add(42*time.Minute), time.Minute))
+		})
+
+		It("should keep waiting while the ManagedResource's observed generation is behind its current generation", func() {
+			createManagedResource(2, 1)
+
+			WithWaitTimeout(50 * time.Millisecond)(gcmx)
+			gcmx.testIsolation.WaitUntilHealthy = func(context.Context, client.Client, string, string) error {
+				Fail("the health of the ManagedResource must not be evaluated based on a stale status")
+				return nil
+			}
+
+			Expect(gcmx.Wait(ctx)).To(
+				MatchError(ContainSubstring("observed generation 1 of the ManagedResource is behind its current generation 2")))
+		})
+
+		It("should evaluate the ManagedResource's health once its observed generation has caught up", func() {
+			createManagedResource(2, 2)
+
+			WithWaitTimeout(50 * time.Millisecond)(gcmx)
+			waitUntilHealthyCallCount := 0
+			gcmx.testIsolation.WaitUntilHealthy = func(context.Context, client.Client, string, string) error {
+				waitUntilHealthyCallCount++
+				return nil
+			}
+
+			Expect(gcmx.Wait(ctx)).To(Succeed())
+			Expect(waitUntilHealthyCallCount).To(Equal(1))
+		})
+	})
+
+	Describe("#WaitForAPIServiceAvailable", func() {
+		createAPIService := func(availableStatus apiregistrationv1.ConditionStatus) {
+			apiService := &apiregistrationv1.APIService{
+				ObjectMeta: metav1.ObjectMeta{Name: "v1beta2." + apiServiceGroup},
+				Status: apiregistrationv1.APIServiceStatus{
+					Conditions: []apiregistrationv1.APIServiceCondition{{
+						Type:   apiregistrationv1.Available,
+						Status: availableStatus,
+					}},
+				},
+			}
+			Expect(seedClient.Create(ctx, apiService)).To(Succeed())
+		}
+
+		BeforeEach(func() {
+			originalRetryInterval := waitForAPIServiceRetryInterval
+			waitForAPIServiceRetryInterval = 1 * time.Millisecond
+			DeferCleanup(func() {
+				waitForAPIServiceRetryInterval = originalRetryInterval
+			})
+			WithWaitTimeout(50 * time.Millisecond)(gcmx)
+		})
+
+		It("should succeed once the APIService reports itself as available", func() {
+			createAPIService(apiregistrationv1.ConditionTrue)
+
+			Expect(gcmx.WaitForAPIServiceAvailable(ctx)).To(Succeed())
+		})
+
+		It("should time out while the APIService reports itself as unavailable", func() {
+			createAPIService(apiregistrationv1.ConditionFalse)
+
+			Expect(gcmx.WaitForAPIServiceAvailable(ctx)).To(
+				MatchError(ContainSubstring("Available condition")))
+		})
+
+		It("should time out while the APIService is missing", func() {
+			Expect(gcmx.WaitForAPIServiceAvailable(ctx)).To(
+				MatchError(ContainSubstring("v1beta2." + apiServiceGroup)))
+		})
+	})
+
+	Describe("#WaitCleanup", func() {
+		It("should honor the configured wait timeout", func() {
+			var deadline time.Time
+			WithWaitTimeout(42 * time.Minute)(gcmx)
+			gcmx.testIsolation.WaitUntilDeleted = func(waitCtx context.Context, _ client.Client, _, _ string) error {
+				deadline, _ = waitCtx.Deadline()
+				return nil
+			}
+
+			before := time.Now()
+			Expect(gcmx.WaitCleanup(ctx)).To(Succeed())
+
+			Expect(deadline).To(BeTemporally("~", before.Add(42*time.Minute), time.Minute))
+		})
+	})
+
+	Describe("#Migrate", func() {
+		It("should delete the ManagedResource but keep the server TLS certificate secret", func() {
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: serverCertificateSecretName, Namespace: namespace},
+			}
+			Expect(seedClient.Create(ctx, secret)).To(Succeed())
+			managedResource := &resourcesv1alpha1.ManagedResource{
+				ObjectMeta: metav1.ObjectMeta{Name: managedResourceName, Namespace: namespace},
+			}
+			Expect(seedClient.Create(ctx, managedResource)).To(Succeed())
+
+			Expect(gcmx.Migrate(ctx)).To(Succeed())
+
+			Expect(deleteForSeedCallCount).To(Equal(1))
+			Expect(deleteForSeedName).To(Equal(managedResourceName))
+			Expect(seedClient.Get(ctx, kutil.Key(namespace, managedResourceName), managedResource)).To(Succeed())
+			Expect(managedResource.Spec.KeepObjects).To(PointTo(BeTrue()))
+			Expect(seedClient.Get(ctx, kutil.Key(namespace, serverCertificateSecretName), &corev1.Secret{})).To(Succeed())
+		})
+
+		It("should succeed if the ManagedResource is missing", func() {
+			Expect(gcmx.Migrate(ctx)).To(Succeed())
+
+			Expect(deleteForSeedCallCount).To(Equal(1))
+		})
+	})
+})
+
+// limitedSecretsManager is a secretsmanager.Interface which reports only the secret with the specified name as
+// existing, and delegates all other behavior to the wrapped manager.
+type limitedSecretsManager struct {
+	secretsmanager.Interface
+	knownName string
+}
+
+func (m *limitedSecretsManager) Get(name string, opts ...secretsmanager.GetOption) (*corev1.Secret, bool) {
+	if name != m.knownName {
+		return nil, false
+	}
+	return m.Interface.Get(name, opts...)
+}
+
+// recordingLogSink is a logr.LogSink which records the messages of all log records passed to it.
+type recordingLogSink struct {
+	messages *[]string
+}
+
+func (s *recordingLogSink) Init(_ logr.RuntimeInfo)        {}
+func (s *recordingLogSink) Enabled(_ int) bool             { return true }
+func (s *recordingLogSink) WithName(_ string) logr.LogSink { return s }
+
+func (s *recordingLogSink) WithValues(_ ...interface{}) logr.LogSink { return s }
+
+func (s *recordingLogSink) Info(_ int, message string, _ ...interface{}) {
+	*s.messages = append(*s.messages, message)
+}
+
+func (s *recordingLogSink) Error(_ error, message string, _ ...interface{}) {
+	*s.messages = append(*s.messages, message)
+}
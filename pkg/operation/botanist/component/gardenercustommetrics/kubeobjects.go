@@ -0,0 +1,776 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gardenercustommetrics
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	v1beta1constants "github.com/gardener/gardener/pkg/apis/core/v1beta1/constants"
+	"github.com/gardener/gardener/pkg/utils"
+	gutil "github.com/gardener/gardener/pkg/utils/gardener"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	apiregistrationv1 "k8s.io/kube-aggregator/pkg/apis/apiregistration/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// This file contains the builder functions for the individual k8s objects which comprise the GCMx deployment.
+
+const (
+	deploymentName = componentBaseName
+	containerName  = componentBaseName
+	serviceName    = componentBaseName
+	// serviceAccountName is the name of GCMx's service account in the seed.
+	serviceAccountName = componentBaseName
+
+	servicePortName       = "https"
+	servicePort     int32 = 443
+	containerPort   int32 = 6443
+
+	// defaultAccessIP is the default value for the address at which the GCMx process serves - a reference to the
+	// POD_IP environment variable, resolved by the container runtime.
+	defaultAccessIP = "$(POD_IP)"
+
+	// serverCertificateMountPath is where the server TLS certificate secret is mounted inside the GCMx container.
+	serverCertificateMountPath = "/var/run/secrets/gardener.cloud/tls"
+
+	// apiServiceGroup is the API group served by GCMx to the seed kube-apiserver.
+	apiServiceGroup = "custom.metrics.k8s.io"
+	// defaultAPIServiceVersion is the version of the custom metrics API served to recent seed versions.
+	defaultAPIServiceVersion = "v1beta2"
+
+	// defaultLivenessProbePath and defaultReadinessProbePath are the HTTPS paths probed to assess container health,
+	// unless overridden via the rendering parameters.
+	defaultLivenessProbePath  = "/healthz"
+	defaultReadinessProbePath = "/readyz"
+
+	// defaultHealthProbePort is the default port at which the GCMx process serves its health probe endpoints. It is
+	// deliberately distinct from containerPort, so that probe traffic does not pass through the authenticated custom
+	// metrics endpoint.
+	defaultHealthProbePort int32 = 6444
+
+	// defaultTerminationGracePeriodSeconds is the default time the kubelet grants the GCMx pod to shut down
+	// gracefully.
+	defaultTerminationGracePeriodSeconds int64 = 30
+
+	// defaultRevisionHistoryLimit is the default number of old ReplicaSets retained to allow rollbacks.
+	defaultRevisionHistoryLimit int32 = 1
+)
+
+// getLabels returns the labels common to all k8s objects deployed as part of GCMx. They also serve as the pod
+// selector of the component's deployment and service, so they must remain stable across versions.
+func getLabels() map[string]string {
+	return map[string]string{
+		v1beta1constants.LabelApp:   componentBaseName,
+		v1beta1constants.GardenRole: v1beta1constants.GardenRoleSystemComponent,
+	}
+}
+
+// getOwnershipMarkerLabels returns the labels which mark an object as owned by this component, independently of the
+// ManagedResource's bookkeeping. Objects which fell out of that bookkeeping - e.g. created out of band, or left
+// behind by a crashed older version - can still be discovered via the marker, and removed by CleanupOrphanedObjects.
+func getOwnershipMarkerLabels() map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/managed-by": "gardener",
+		"app.kubernetes.io/instance":   componentBaseName,
+	}
+}
+
+// getObjectLabels returns the labels placed on the metadata of every k8s object built by this package: the common
+// component labels, plus the ownership marker.
+func getObjectLabels() map[string]string {
+	return utils.MergeStringMaps(getLabels(), getOwnershipMarkerLabels())
+}
+
+// kubeObjectsParameters bundles the input which drives the rendering of the component's k8s objects.
+type kubeObjectsParameters struct {
+	// Namespace is the seed namespace into which the component's namespaced objects are rendered.
+	Namespace string
+	// ContainerImageName is the fully qualified reference of the GCMx container image.
+	ContainerImageName string
+	// ServerCertificateSecretName is the name of the secret holding GCMx's server TLS certificate.
+	ServerCertificateSecretName string
+	// CABundle is the bundle of CA certificates advertised to the seed kube-apiserver via the APIService object.
+	CABundle []byte
+	// APIServiceVersion is the version of the custom metrics API registered with the seed kube-apiserver.
+	APIServiceVersion string
+	// AccessIP and AccessPort specify the address at which the GCMx process serves; use defaultAccessIP and
+	// containerPort respectively, unless the component is deployed behind a different advertised address.
+	AccessIP   string
+	AccessPort int32
+	// Replicas is the number of GCMx pod replicas.
+	Replicas int32
+	// NodeSelector, if set, constrains the GCMx pod to nodes matching the selector.
+	NodeSelector map[string]string
+	// Tolerations, if set, are attached to the GCMx pod.
+	Tolerations []corev1.Toleration
+	// Affinity, if set, overrides the affinity rules which would otherwise be derived from the replica count.
+	Affinity *corev1.Affinity
+	// TopologySpreadConstraints, if set, governs how the GCMx pods are spread across the seed's topology domains.
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint
+	// ContainerName is the name of the GCMx container inside the deployment's pod. Objects which reference the
+	// container by name (e.g. a VPA's container resource policy) must derive from this same value, so that the two
+	// cannot silently drift apart.
+	ContainerName string
+	// LivenessProbePath and ReadinessProbePath are the HTTPS paths probed to assess the health of the GCMx container.
+	LivenessProbePath  string
+	ReadinessProbePath string
+	// ProbePort is the port at which the probe endpoints are served, over TLS. It is rendered both into the
+	// container's --health-probe-port argument and into the probe definitions, so the two cannot silently drift
+	// apart. It must differ from AccessPort - see computeResourcesData, which rejects a colliding configuration.
+	ProbePort int32
+	// TerminationGracePeriodSeconds is the time the kubelet grants the GCMx pod to shut down gracefully.
+	TerminationGracePeriodSeconds int64
+	// PreStopSleepSeconds, if positive, installs a preStop hook which sleeps for the specified duration before the
+	// container receives its termination signal, allowing in-flight custom metrics requests to bleed off during
+	// leader handoff.
+	PreStopSleepSeconds int32
+	// RevisionHistoryLimit is the number of old ReplicaSets retained to allow rollbacks of the GCMx deployment.
+	RevisionHistoryLimit *int32
+	// DeploymentStrategy, if set, overrides the rollout strategy of the GCMx deployment. If nil, the strategy is left
+	// empty and the k8s default (rolling update) applies.
+	DeploymentStrategy *appsv1.DeploymentStrategy
+	// ResourceLimits, if set, caps the resource consumption of the GCMx container. The built-in resource requests
+	// remain in place either way. If the container is later put under a VPA with 'RequestsOnly' controlled values,
+	// the limits stay as configured here while the requests are adjusted. By default, no limits are applied.
+	ResourceLimits corev1.ResourceList
+	// ScrapeInterval, if positive, overrides the cadence at which GCMx scrapes the shoot kube-apiserver metrics
+	// endpoints. If zero, the GCMx binary's built-in default applies.
+	ScrapeInterval time.Duration
+	// ExtraEnv, if set, are appended to the built-in environment variables of the GCMx container. A variable whose
+	// name collides with a built-in one is dropped - the built-in value wins; see makeEnv.
+	ExtraEnv []corev1.EnvVar
+	// ExtraVolumes, if set, are appended to the built-in volumes of the GCMx pod. They must not clash with the
+	// built-in volume names.
+	ExtraVolumes []corev1.Volume
+	// ExtraVolumeMounts, if set, are appended to the built-in volume mounts of the GCMx container.
+	ExtraVolumeMounts []corev1.VolumeMount
+	// ServiceSessionAffinity is the session affinity of the GCMx service. Defaults to 'None' - see
+	// defaultKubeObjectsParameters - which matches the historical behavior of the service.
+	ServiceSessionAffinity corev1.ServiceAffinity
+	// ServicePublishNotReadyAddresses specifies whether the GCMx service also publishes the addresses of not-ready
+	// pods. Defaults to false, which matches the historical behavior of the service.
+	ServicePublishNotReadyAddresses bool
+	// ShootAPIServerEgressCIDRs, if non-empty, causes an explicit egress NetworkPolicy to be rendered which allows
+	// the GCMx pods TCP 443 egress to the specified shoot kube-apiserver CIDRs - for seeds where the label-driven
+	// 'to-shoot-apiserver' policy is not in effect. If empty, no such policy is rendered and egress is governed
+	// solely by the network policy labels on the GCMx pod.
+	ShootAPIServerEgressCIDRs []string
+	// RestrictedSecretNames, if non-empty, narrows the secrets access granted by the component's ClusterRole to the
+	// named secrets - see makeClusterRole. If empty, the secrets access is unrestricted.
+	RestrictedSecretNames []string
+	// RegisterAPIService specifies whether GCMx is registered with the seed's aggregation layer as the provider of
+	// the custom metrics API. If false, the APIService and the RBAC bindings backing the aggregation handshake are
+	// omitted, and a ServiceMonitor (see makeServiceMonitor) is rendered instead, so that the component's metrics
+	// remain reachable via direct prometheus scraping (push mode).
+	RegisterAPIService bool
+}
+
+// defaultKubeObjectsParameters returns rendering parameters which reflect the component's default configuration in
+// the specified namespace.
+func defaultKubeObjectsParameters(namespace string) kubeObjectsParameters {
+	return kubeObjectsParameters{
+		Namespace:                   namespace,
+		ServerCertificateSecretName: serverCertificateSecretName,
+		APIServiceVersion:           defaultAPIServiceVersion,
+		AccessIP:                    defaultAccessIP,
+		AccessPort:                  containerPort,
+		Replicas:                    1,
+		ContainerName:               containerName,
+		LivenessProbePath:           defaultLivenessProbePath,
+		ReadinessProbePath:          defaultReadinessProbePath,
+		ProbePort:                   defaultHealthProbePort,
+		ServiceSessionAffinity:      corev1.ServiceAffinityNone,
+
+		TerminationGracePeriodSeconds: defaultTerminationGracePeriodSeconds,
+		RevisionHistoryLimit:          pointer.Int32(defaultRevisionHistoryLimit),
+		RegisterAPIService:            true,
+	}
+}
+
+// makeKubeObjects returns all k8s objects which comprise the GCMx deployment, in their desired state.
+func makeKubeObjects(params kubeObjectsParameters) []client.Object {
+	objects := []client.Object{
+		makeServiceAccount(params.Namespace),
+		makeClusterRole(params.RestrictedSecretNames),
+		makeClusterRoleBinding(params.Namespace),
+		makeLeaderElectionRole(params.Namespace),
+		makeLeaderElectionRoleBinding(params.Namespace),
+		makeService(params),
+		makeDeployment(params),
+	}
+
+	if len(params.ShootAPIServerEgressCIDRs) > 0 {
+		objects = append(objects, makeShootAPIServerEgressNetworkPolicy(params.Namespace, params.ShootAPIServerEgressCIDRs))
+	}
+
+	if params.RegisterAPIService {
+		objects = append(objects,
+			makeAuthDelegatorClusterRoleBinding(params.Namespace),
+			makeAuthReaderRoleBinding(params.Namespace),
+			makeAPIService(params.Namespace, params.CABundle, params.APIServiceVersion),
+		)
+	}
+
+	return objects
+}
+
+// ExpectedObjectRefs returns the keys of all k8s objects which GCMx deploys to the specified namespace, in its
+// default configuration. It is meant as a single source of truth for orphan detection, and is derived from the same
+// builders which produce the actual objects. Cluster-scoped objects are represented by keys with an empty namespace.
+func ExpectedObjectRefs(namespace string) []client.ObjectKey {
+	objects := makeKubeObjects(defaultKubeObjectsParameters(namespace))
+
+	refs := make([]client.ObjectKey, 0, len(objects))
+	for _, object := range objects {
+		refs = append(refs, client.ObjectKeyFromObject(object))
+	}
+	return refs
+}
+
+// CleanupOrphanedObjects deletes all objects in the specified namespace which carry the component's ownership marker
+// labels (see getOwnershipMarkerLabels), along with the component's marked cluster-scoped objects. It is meant for
+// removing leftovers which are no longer tracked by the component's ManagedResource, and covers the object types
+// produced by makeKubeObjects.
+func CleanupOrphanedObjects(ctx context.Context, seedClient client.Client, namespace string) error {
+	marker := client.MatchingLabels(getOwnershipMarkerLabels())
+
+	for _, object := range []client.Object{
+		&corev1.ServiceAccount{},
+		&corev1.Service{},
+		&appsv1.Deployment{},
+		&rbacv1.Role{},
+		&rbacv1.RoleBinding{},
+		&networkingv1.NetworkPolicy{},
+	} {
+		if err := seedClient.DeleteAllOf(ctx, object, client.InNamespace(namespace), marker); err != nil {
+			return fmt.Errorf("failed to delete the orphaned %T objects of the %s component in namespace '%s': %w",
+				object, componentBaseName, namespace, err)
+		}
+	}
+
+	// The auth-reader RoleBinding lives in the kube-system namespace - see makeAuthReaderRoleBinding
+	if err := seedClient.DeleteAllOf(
+		ctx, &rbacv1.RoleBinding{}, client.InNamespace(metav1.NamespaceSystem), marker); err != nil {
+
+		return fmt.Errorf("failed to delete the orphaned RoleBinding objects of the %s component in namespace '%s': %w",
+			componentBaseName, metav1.NamespaceSystem, err)
+	}
+
+	for _, object := range []client.Object{
+		&rbacv1.ClusterRole{},
+		&rbacv1.ClusterRoleBinding{},
+		&apiregistrationv1.APIService{},
+	} {
+		if err := seedClient.DeleteAllOf(ctx, object, marker); err != nil {
+			return fmt.Errorf("failed to delete the orphaned cluster-scoped %T objects of the %s component: %w",
+				object, componentBaseName, err)
+		}
+	}
+
+	return nil
+}
+
+func makeServiceAccount(namespace string) *corev1.ServiceAccount {
+	return &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      serviceAccountName,
+			Namespace: namespace,
+			Labels:    getObjectLabels(),
+		},
+		AutomountServiceAccountToken: pointer.Bool(false),
+	}
+}
+
+// ExpectedShootSecretNames returns the names of the per-shoot secrets which GCMx reads from a shoot's control plane
+// namespace in the seed in order to scrape that shoot's kube-apiserver over mTLS: the shoot cluster CA bundle, with
+// which it verifies the kube-apiserver's serving certificate, and the shoot access secret carrying the scrape
+// credentials. The read is permitted by the secrets rule of the component's ClusterRole (see makeClusterRole), which
+// deliberately carries no resourceNames restriction; this accessor records the component's actual expectations, so
+// that operators can audit - or narrow - that access.
+func ExpectedShootSecretNames() []string {
+	return []string{
+		v1beta1constants.SecretNameCACluster,
+		gutil.SecretNamePrefixShootAccess + componentBaseName,
+	}
+}
+
+// makeClusterRole returns the ClusterRole which allows GCMx to discover the shoot kube-apiserver pods it scrapes,
+// and the secrets it needs to authenticate against them - see ExpectedShootSecretNames for the specific secrets the
+// component reads per shoot. By default, the secrets access is unrestricted, matching the other resources. If
+// restrictedSecretNames is non-empty, the secrets access is narrowed to the named secrets instead; RBAC does not
+// support resourceNames on list and watch requests, so the narrowed rule only grants 'get'.
+func makeClusterRole(restrictedSecretNames []string) *rbacv1.ClusterRole {
+	readRules := []rbacv1.PolicyRule{{
+		APIGroups: []string{""},
+		Resources: []string{"pods", "secrets", "namespaces", "configmaps"},
+		Verbs:     []string{"get", "list", "watch"},
+	}}
+
+	if len(restrictedSecretNames) > 0 {
+		readRules = []rbacv1.PolicyRule{
+			{
+				APIGroups: []string{""},
+				Resources: []string{"pods", "namespaces", "configmaps"},
+				Verbs:     []string{"get", "list", "watch"},
+			},
+			{
+				APIGroups:     []string{""},
+				Resources:     []string{"secrets"},
+				ResourceNames: restrictedSecretNames,
+				Verbs:         []string{"get"},
+			},
+		}
+	}
+
+	return &rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "gardener.cloud:" + componentBaseName,
+			Labels: getObjectLabels(),
+		},
+		Rules: append(readRules,
+			rbacv1.PolicyRule{
+				APIGroups: []string{"authentication.k8s.io"},
+				Resources: []string{"tokenreviews"},
+				Verbs:     []string{"create"},
+			},
+			rbacv1.PolicyRule{
+				APIGroups: []string{"authorization.k8s.io"},
+				Resources: []string{"subjectaccessreviews"},
+				Verbs:     []string{"create"},
+			},
+		),
+	}
+}
+
+func makeClusterRoleBinding(namespace string) *rbacv1.ClusterRoleBinding {
+	return &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "gardener.cloud:" + componentBaseName,
+			Labels: getObjectLabels(),
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     "gardener.cloud:" + componentBaseName,
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      serviceAccountName,
+			Namespace: namespace,
+		}},
+	}
+}
+
+// makeAuthDelegatorClusterRoleBinding allows GCMx, as an aggregated API server, to delegate authentication decisions
+// to the seed kube-apiserver.
+func makeAuthDelegatorClusterRoleBinding(namespace string) *rbacv1.ClusterRoleBinding {
+	return &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   componentBaseName + ":system:auth-delegator",
+			Labels: getObjectLabels(),
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     "system:auth-delegator",
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      serviceAccountName,
+			Namespace: namespace,
+		}},
+	}
+}
+
+// makeAuthReaderRoleBinding allows GCMx to read the extension-apiserver-authentication ConfigMap in the kube-system
+// namespace.
+func makeAuthReaderRoleBinding(namespace string) *rbacv1.RoleBinding {
+	return &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      componentBaseName + "-auth-reader",
+			Namespace: metav1.NamespaceSystem,
+			Labels:    getObjectLabels(),
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "Role",
+			Name:     "extension-apiserver-authentication-reader",
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      serviceAccountName,
+			Namespace: namespace,
+		}},
+	}
+}
+
+func makeLeaderElectionRole(namespace string) *rbacv1.Role {
+	return &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      componentBaseName + "-leader-election",
+			Namespace: namespace,
+			Labels:    getObjectLabels(),
+		},
+		Rules: []rbacv1.PolicyRule{{
+			APIGroups: []string{"coordination.k8s.io"},
+			Resources: []string{"leases"},
+			Verbs:     []string{"create", "get", "list", "watch", "update"},
+		}},
+	}
+}
+
+func makeLeaderElectionRoleBinding(namespace string) *rbacv1.RoleBinding {
+	return &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      componentBaseName + "-leader-election",
+			Namespace: namespace,
+			Labels:    getObjectLabels(),
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "Role",
+			Name:     componentBaseName + "-leader-election",
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      serviceAccountName,
+			Namespace: namespace,
+		}},
+	}
+}
+
+// makeService returns the GCMx service. Its target port is derived from the same access port value which drives the
+// deployment's container port, so that the two cannot silently drift apart.
+func makeService(params kubeObjectsParameters) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      serviceName,
+			Namespace: params.Namespace,
+			Labels:    getObjectLabels(),
+		},
+		Spec: corev1.ServiceSpec{
+			Type:                     corev1.ServiceTypeClusterIP,
+			Selector:                 getLabels(),
+			SessionAffinity:          params.ServiceSessionAffinity,
+			PublishNotReadyAddresses: params.ServicePublishNotReadyAddresses,
+			Ports: []corev1.ServicePort{{
+				Name:       servicePortName,
+				Protocol:   corev1.ProtocolTCP,
+				Port:       servicePort,
+				TargetPort: intstr.FromInt(int(params.AccessPort)),
+			}},
+		},
+	}
+}
+
+// makeDeployment returns the GCMx deployment. The container port is kept consistent with the access port specified by
+// the parameters.
+func makeDeployment(params kubeObjectsParameters) *appsv1.Deployment {
+	const serverCertificateVolumeName = "gardener-custom-metrics-tls"
+
+	var strategy appsv1.DeploymentStrategy
+	if params.DeploymentStrategy != nil {
+		strategy = *params.DeploymentStrategy
+	}
+
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      deploymentName,
+			Namespace: params.Namespace,
+			Labels:    getObjectLabels(),
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas:             pointer.Int32(params.Replicas),
+			RevisionHistoryLimit: params.RevisionHistoryLimit,
+			Strategy:             strategy,
+			Selector:             &metav1.LabelSelector{MatchLabels: getLabels()},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: utils.MergeStringMaps(getObjectLabels(), map[string]string{
+						v1beta1constants.LabelNetworkPolicyToDNS:            v1beta1constants.LabelNetworkPolicyAllowed,
+						v1beta1constants.LabelNetworkPolicyToSeedAPIServer:  v1beta1constants.LabelNetworkPolicyAllowed,
+						v1beta1constants.LabelNetworkPolicyToShootAPIServer: v1beta1constants.LabelNetworkPolicyAllowed,
+						v1beta1constants.LabelNetworkPolicyFromPrometheus:   v1beta1constants.LabelNetworkPolicyAllowed,
+					}),
+				},
+				Spec: corev1.PodSpec{
+					ServiceAccountName:            serviceAccountName,
+					NodeSelector:                  params.NodeSelector,
+					Tolerations:                   params.Tolerations,
+					Affinity:                      makeAffinity(params),
+					TopologySpreadConstraints:     params.TopologySpreadConstraints,
+					TerminationGracePeriodSeconds: pointer.Int64(params.TerminationGracePeriodSeconds),
+					Containers: []corev1.Container{{
+						Name:            params.ContainerName,
+						Image:           params.ContainerImageName,
+						ImagePullPolicy: corev1.PullIfNotPresent,
+						Command:         makeCommand(params),
+						Env:             makeEnv(params),
+						Ports: []corev1.ContainerPort{{
+							Name:          servicePortName,
+							ContainerPort: params.AccessPort,
+							Protocol:      corev1.ProtocolTCP,
+						}},
+						// The readiness probe gates the pod's inclusion in the service endpoints, and with it the
+						// traffic arriving via the APIService registration - only a ready replica serves the custom
+						// metrics API.
+						LivenessProbe:  makeProbe(params.LivenessProbePath, params.ProbePort, 30),
+						ReadinessProbe: makeProbe(params.ReadinessProbePath, params.ProbePort, 5),
+						Lifecycle:      makeLifecycle(params),
+						Resources: corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{
+								corev1.ResourceCPU:    resource.MustParse("80m"),
+								corev1.ResourceMemory: resource.MustParse("800Mi"),
+							},
+							Limits: params.ResourceLimits,
+						},
+						VolumeMounts: append([]corev1.VolumeMount{{
+							Name:      serverCertificateVolumeName,
+							MountPath: serverCertificateMountPath,
+							ReadOnly:  true,
+						}}, params.ExtraVolumeMounts...),
+					}},
+					Volumes: append([]corev1.Volume{{
+						Name: serverCertificateVolumeName,
+						VolumeSource: corev1.VolumeSource{
+							Secret: &corev1.SecretVolumeSource{
+								SecretName: params.ServerCertificateSecretName,
+							},
+						},
+					}}, params.ExtraVolumes...),
+				},
+			},
+		},
+	}
+}
+
+// makeEnv returns the environment variables of the GCMx container: the built-in variables, followed by the extra
+// variables from the parameters. An extra variable whose name collides with a built-in one is dropped - the command
+// line rendered by makeCommand relies on the built-in values, so they must not be overridden.
+func makeEnv(params kubeObjectsParameters) []corev1.EnvVar {
+	env := []corev1.EnvVar{{
+		Name: "POD_IP",
+		ValueFrom: &corev1.EnvVarSource{
+			FieldRef: &corev1.ObjectFieldSelector{FieldPath: "status.podIP"},
+		},
+	}}
+
+	builtInNames := map[string]struct{}{}
+	for _, variable := range env {
+		builtInNames[variable.Name] = struct{}{}
+	}
+
+	for _, variable := range params.ExtraEnv {
+		if _, isBuiltIn := builtInNames[variable.Name]; isBuiltIn {
+			continue
+		}
+		env = append(env, variable)
+	}
+
+	return env
+}
+
+// makeCommand returns the command line of the GCMx container.
+func makeCommand(params kubeObjectsParameters) []string {
+	command := []string{
+		"./gardener-custom-metrics",
+		fmt.Sprintf("--secure-port=%d", params.AccessPort),
+		fmt.Sprintf("--access-ip=%s", params.AccessIP),
+		fmt.Sprintf("--access-port=%d", params.AccessPort),
+		fmt.Sprintf("--tls-cert-file=%s/tls.crt", serverCertificateMountPath),
+		fmt.Sprintf("--tls-private-key-file=%s/tls.key", serverCertificateMountPath),
+		"--leader-election=true",
+		fmt.Sprintf("--health-probe-port=%d", params.ProbePort),
+		fmt.Sprintf("--namespace=%s", params.Namespace),
+		"--log-level=74",
+	}
+
+	if params.ScrapeInterval > 0 {
+		command = append(command, fmt.Sprintf("--scrape-interval=%s", params.ScrapeInterval))
+	}
+
+	return command
+}
+
+// makeProbe returns an HTTPS probe against the specified path and port of the GCMx container.
+func makeProbe(path string, port int32, initialDelaySeconds int32) *corev1.Probe {
+	return &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{
+			HTTPGet: &corev1.HTTPGetAction{
+				Path:   path,
+				Port:   intstr.FromInt(int(port)),
+				Scheme: corev1.URISchemeHTTPS,
+			},
+		},
+		InitialDelaySeconds: initialDelaySeconds,
+		PeriodSeconds:       10,
+		FailureThreshold:    3,
+	}
+}
+
+// makeLifecycle returns the lifecycle hooks for the GCMx container, or nil if none are configured. A positive
+// PreStopSleepSeconds value translates to a preStop hook which delays the container's termination signal by that
+// long.
+func makeLifecycle(params kubeObjectsParameters) *corev1.Lifecycle {
+	if params.PreStopSleepSeconds <= 0 {
+		return nil
+	}
+
+	return &corev1.Lifecycle{
+		PreStop: &corev1.LifecycleHandler{
+			Exec: &corev1.ExecAction{
+				Command: []string{"sh", "-c", fmt.Sprintf("sleep %d", params.PreStopSleepSeconds)},
+			},
+		},
+	}
+}
+
+// makeAffinity returns the affinity rules for the GCMx pod. An explicitly specified affinity takes precedence. In
+// its absence, a multi-replica deployment receives a preferred pod anti-affinity which spreads the replicas across
+// nodes, while a single-replica deployment receives no affinity rules at all.
+func makeAffinity(params kubeObjectsParameters) *corev1.Affinity {
+	if params.Affinity != nil {
+		return params.Affinity
+	}
+
+	if params.Replicas <= 1 {
+		return nil
+	}
+
+	return &corev1.Affinity{
+		PodAntiAffinity: &corev1.PodAntiAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{{
+				Weight: 100,
+				PodAffinityTerm: corev1.PodAffinityTerm{
+					TopologyKey: corev1.LabelHostname,
+					LabelSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{v1beta1constants.LabelApp: componentBaseName},
+					},
+				},
+			}},
+		},
+	}
+}
+
+// makeShootAPIServerEgressNetworkPolicy returns a NetworkPolicy which allows the GCMx pods TCP 443 egress to the
+// specified shoot kube-apiserver CIDRs. It is only rendered if such CIDRs are configured - see
+// kubeObjectsParameters.ShootAPIServerEgressCIDRs.
+func makeShootAPIServerEgressNetworkPolicy(namespace string, cidrs []string) *networkingv1.NetworkPolicy {
+	peers := make([]networkingv1.NetworkPolicyPeer, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		peers = append(peers, networkingv1.NetworkPolicyPeer{
+			IPBlock: &networkingv1.IPBlock{
+				CIDR: cidr,
+			},
+		})
+	}
+
+	protocol := corev1.ProtocolTCP
+	port := intstr.FromInt(443)
+
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "allow-" + componentBaseName + "-to-shoot-apiserver",
+			Namespace: namespace,
+			Labels:    getObjectLabels(),
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: getLabels()},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+			Egress: []networkingv1.NetworkPolicyEgressRule{{
+				To: peers,
+				Ports: []networkingv1.NetworkPolicyPort{{
+					Protocol: &protocol,
+					Port:     &port,
+				}},
+			}},
+		},
+	}
+}
+
+// makeServiceMonitor returns the ServiceMonitor through which the seed's prometheus scrapes the GCMx metrics
+// endpoint directly, when the component runs in push mode and does not serve the custom metrics API. The
+// prometheus-operator API types are not part of the seed scheme, so the object is represented as unstructured data
+// and bypasses the typed object registry during serialization - see computeResourcesData.
+func makeServiceMonitor(namespace string) *unstructured.Unstructured {
+	labels := map[string]interface{}{}
+	for key, value := range getObjectLabels() {
+		labels[key] = value
+	}
+	matchLabels := map[string]interface{}{}
+	for key, value := range getLabels() {
+		matchLabels[key] = value
+	}
+
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "monitoring.coreos.com/v1",
+		"kind":       "ServiceMonitor",
+		"metadata": map[string]interface{}{
+			"name":      componentBaseName,
+			"namespace": namespace,
+			"labels":    labels,
+		},
+		"spec": map[string]interface{}{
+			"selector": map[string]interface{}{
+				"matchLabels": matchLabels,
+			},
+			"endpoints": []interface{}{
+				map[string]interface{}{
+					"port":   servicePortName,
+					"path":   "/metrics",
+					"scheme": "https",
+					"tlsConfig": map[string]interface{}{
+						// The metrics endpoint is scraped via pod IP, which the server certificate does not cover
+						"insecureSkipVerify": true,
+					},
+				},
+			},
+		},
+	}}
+}
+
+// makeAPIService returns the APIService object which registers GCMx with the seed kube-apiserver as the provider of
+// the custom metrics API, in the specified version.
+func makeAPIService(namespace string, caBundle []byte, apiServiceVersion string) *apiregistrationv1.APIService {
+	return &apiregistrationv1.APIService{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   apiServiceVersion + "." + apiServiceGroup,
+			Labels: getObjectLabels(),
+		},
+		Spec: apiregistrationv1.APIServiceSpec{
+			Service: &apiregistrationv1.ServiceReference{
+				Name:      serviceName,
+				Namespace: namespace,
+				Port:      pointer.Int32(servicePort),
+			},
+			Group:                apiServiceGroup,
+			Version:              apiServiceVersion,
+			CABundle:             caBundle,
+			GroupPriorityMinimum: 100,
+			VersionPriority:      100,
+		},
+	}
+}
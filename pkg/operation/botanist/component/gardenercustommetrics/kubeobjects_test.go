@@ -0,0 +1,572 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gardenercustommetrics
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gardener/gardener/pkg/client/kubernetes"
+	. "github.com/gardener/gardener/pkg/utils/test/matchers"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gstruct"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("KubeObjects", func() {
+	const (
+		namespace          = "shoot--foo--bar"
+		containerImageName = "test-image:test-version"
+	)
+
+	// Returns the deployment among the specified objects, failing the test if there is none.
+	getDeployment := func(objects []client.Object) *appsv1.Deployment {
+		for _, object := range objects {
+			if deployment, ok := object.(*appsv1.Deployment); ok {
+				return deployment
+			}
+		}
+		Fail("the object list does not contain a deployment")
+		return nil
+	}
+
+	// Returns the service among the specified objects, failing the test if there is none.
+	getService := func(objects []client.Object) *corev1.Service {
+		for _, object := range objects {
+			if service, ok := object.(*corev1.Service); ok {
+				return service
+			}
+		}
+		Fail("the object list does not contain a service")
+		return nil
+	}
+
+	makeDefaultParameters := func() kubeObjectsParameters {
+		params := defaultKubeObjectsParameters(namespace)
+		params.ContainerImageName = containerImageName
+		return params
+	}
+
+	makeDefaultKubeObjects := func() []client.Object {
+		return makeKubeObjects(makeDefaultParameters())
+	}
+
+	Describe("#makeClusterRole", func() {
+		It("should grant unrestricted read access to secrets by default", func() {
+			clusterRole := makeClusterRole(nil)
+
+			Expect(clusterRole.Rules[0].Resources).To(ContainElement("secrets"))
+			Expect(clusterRole.Rules[0].ResourceNames).To(BeEmpty())
+			Expect(clusterRole.Rules[0].Verbs).To(ConsistOf("get", "list", "watch"))
+		})
+
+		It("should narrow the secrets access to the specified names", func() {
+			clusterRole := makeClusterRole([]string{"ca", "shoot-access-gardener-custom-metrics"})
+
+			var broadRules, secretsRules []rbacv1.PolicyRule
+			for _, rule := range clusterRole.Rules {
+				switch {
+				case len(rule.ResourceNames) > 0:
+					secretsRules = append(secretsRules, rule)
+				default:
+					broadRules = append(broadRules, rule)
+				}
+			}
+
+			Expect(secretsRules).To(HaveLen(1))
+			Expect(secretsRules[0].Resources).To(ConsistOf("secrets"))
+			Expect(secretsRules[0].ResourceNames).To(ConsistOf("ca", "shoot-access-gardener-custom-metrics"))
+			// RBAC cannot restrict list and watch requests by resource name
+			Expect(secretsRules[0].Verbs).To(ConsistOf("get"))
+			for _, rule := range broadRules {
+				Expect(rule.Resources).NotTo(ContainElement("secrets"))
+			}
+		})
+	})
+
+	Describe("#ExpectedShootSecretNames", func() {
+		It("should stay within the secrets access granted by the ClusterRole", func() {
+			clusterRole := makeClusterRole(nil)
+
+			for _, rule := range clusterRole.Rules {
+				grantsSecretsAccess := false
+				for _, resource := range rule.Resources {
+					if resource == "secrets" {
+						grantsSecretsAccess = true
+					}
+				}
+				if !grantsSecretsAccess || len(rule.ResourceNames) == 0 {
+					// A rule without resourceNames grants access to all secrets, covering the expected ones
+					continue
+				}
+
+				for _, name := range ExpectedShootSecretNames() {
+					Expect(rule.ResourceNames).To(ContainElement(name))
+				}
+			}
+		})
+
+		It("should expect the shoot CA bundle and the component's shoot access secret", func() {
+			Expect(ExpectedShootSecretNames()).To(ConsistOf("ca", "shoot-access-gardener-custom-metrics"))
+		})
+	})
+
+	Describe("#makeKubeObjects", func() {
+		It("should point the --namespace argument to the deployment's own namespace", func() {
+			objects := makeDefaultKubeObjects()
+
+			deployment := getDeployment(objects)
+			Expect(deployment.Namespace).To(Equal(namespace))
+			Expect(deployment.Spec.Template.Spec.Containers[0].Command).To(
+				ContainElement("--namespace=" + namespace))
+		})
+
+		It("should point the access arguments to the pod IP and container port by default", func() {
+			objects := makeDefaultKubeObjects()
+
+			container := getDeployment(objects).Spec.Template.Spec.Containers[0]
+			Expect(container.Command).To(ContainElement("--access-ip=$(POD_IP)"))
+			Expect(container.Command).To(ContainElement(fmt.Sprintf("--access-port=%d", containerPort)))
+		})
+
+		It("should keep ExpectedObjectRefs in sync with the produced objects", func() {
+			objects := makeDefaultKubeObjects()
+			refs := ExpectedObjectRefs(namespace)
+
+			Expect(refs).To(HaveLen(len(objects)))
+			for i, object := range objects {
+				Expect(refs[i]).To(Equal(client.ObjectKeyFromObject(object)))
+			}
+		})
+
+		It("should keep the service port name consistent with the container port name", func() {
+			objects := makeDefaultKubeObjects()
+
+			service := getService(objects)
+			container := getDeployment(objects).Spec.Template.Spec.Containers[0]
+			Expect(service.Spec.Ports[0].Name).NotTo(BeEmpty())
+			Expect(service.Spec.Ports[0].Name).To(Equal(container.Ports[0].Name))
+		})
+
+		It("should keep the service target port consistent with the deployment's container port", func() {
+			for _, accessPort := range []int32{containerPort, 8443} {
+				params := makeDefaultParameters()
+				params.AccessPort = accessPort
+				objects := makeKubeObjects(params)
+
+				service := getService(objects)
+				container := getDeployment(objects).Spec.Template.Spec.Containers[0]
+				Expect(service.Spec.Ports[0].TargetPort.IntVal).To(Equal(container.Ports[0].ContainerPort))
+			}
+		})
+
+		It("should keep the container port consistent with a custom access port", func() {
+			const customAccessPort int32 = 8443
+
+			params := makeDefaultParameters()
+			params.AccessPort = customAccessPort
+			objects := makeKubeObjects(params)
+
+			container := getDeployment(objects).Spec.Template.Spec.Containers[0]
+			Expect(container.Command).To(ContainElement(fmt.Sprintf("--access-port=%d", customAccessPort)))
+			Expect(container.Command).To(ContainElement(fmt.Sprintf("--secure-port=%d", customAccessPort)))
+			Expect(container.Ports[0].ContainerPort).To(Equal(customAccessPort))
+		})
+
+		It("should name the container after the component by default", func() {
+			container := getDeployment(makeDefaultKubeObjects()).Spec.Template.Spec.Containers[0]
+
+			Expect(container.Name).To(Equal("gardener-custom-metrics"))
+		})
+
+		It("should render a configured container name", func() {
+			params := makeDefaultParameters()
+			params.ContainerName = "custom-metrics-server"
+			container := getDeployment(makeKubeObjects(params)).Spec.Template.Spec.Containers[0]
+
+			Expect(container.Name).To(Equal("custom-metrics-server"))
+		})
+
+		It("should render only the built-in environment variables by default", func() {
+			container := getDeployment(makeDefaultKubeObjects()).Spec.Template.Spec.Containers[0]
+
+			Expect(container.Env).To(HaveLen(1))
+			Expect(container.Env[0].Name).To(Equal("POD_IP"))
+		})
+
+		It("should append the configured extra environment variables after the built-in ones", func() {
+			extraVariable := corev1.EnvVar{Name: "HTTPS_PROXY", Value: "http://proxy.example.com:3128"}
+
+			params := makeDefaultParameters()
+			params.ExtraEnv = []corev1.EnvVar{extraVariable}
+			container := getDeployment(makeKubeObjects(params)).Spec.Template.Spec.Containers[0]
+
+			Expect(container.Env).To(HaveLen(2))
+			Expect(container.Env[0].Name).To(Equal("POD_IP"))
+			Expect(container.Env[1]).To(Equal(extraVariable))
+		})
+
+		It("should drop an extra environment variable which collides with a built-in one", func() {
+			params := makeDefaultParameters()
+			params.ExtraEnv = []corev1.EnvVar{{Name: "POD_IP", Value: "10.0.0.1"}}
+			container := getDeployment(makeKubeObjects(params)).Spec.Template.Spec.Containers[0]
+
+			Expect(container.Env).To(HaveLen(1))
+			Expect(container.Env[0].Value).To(BeEmpty())
+			Expect(container.Env[0].ValueFrom).NotTo(BeNil())
+		})
+
+		It("should render only the server certificate volume and mount by default", func() {
+			podSpec := getDeployment(makeDefaultKubeObjects()).Spec.Template.Spec
+
+			Expect(podSpec.Volumes).To(HaveLen(1))
+			Expect(podSpec.Containers[0].VolumeMounts).To(HaveLen(1))
+		})
+
+		It("should append the configured extra volumes and mounts after the built-in ones", func() {
+			extraVolume := corev1.Volume{
+				Name: "extra-trust-bundle",
+				VolumeSource: corev1.VolumeSource{
+					ConfigMap: &corev1.ConfigMapVolumeSource{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "extra-trust-bundle"},
+					},
+				},
+			}
+			extraVolumeMount := corev1.VolumeMount{
+				Name:      "extra-trust-bundle",
+				MountPath: "/var/run/extra-trust-bundle",
+				ReadOnly:  true,
+			}
+
+			params := makeDefaultParameters()
+			params.ExtraVolumes = []corev1.Volume{extraVolume}
+			params.ExtraVolumeMounts = []corev1.VolumeMount{extraVolumeMount}
+			podSpec := getDeployment(makeKubeObjects(params)).Spec.Template.Spec
+
+			Expect(podSpec.Volumes).To(HaveLen(2))
+			Expect(podSpec.Volumes[1]).To(Equal(extraVolume))
+			Expect(podSpec.Containers[0].VolumeMounts).To(HaveLen(2))
+			Expect(podSpec.Containers[0].VolumeMounts[1]).To(Equal(extraVolumeMount))
+		})
+
+		It("should render no node selector and no tolerations by default", func() {
+			podSpec := getDeployment(makeDefaultKubeObjects()).Spec.Template.Spec
+
+			Expect(podSpec.NodeSelector).To(BeEmpty())
+			Expect(podSpec.Tolerations).To(BeEmpty())
+		})
+
+		It("should render the configured node selector and tolerations into the pod spec", func() {
+			nodeSelector := map[string]string{"worker.gardener.cloud/pool": "monitoring"}
+			toleration := corev1.Toleration{
+				Key:      "dedicated",
+				Operator: corev1.TolerationOpEqual,
+				Value:    "monitoring",
+				Effect:   corev1.TaintEffectNoSchedule,
+			}
+
+			params := makeDefaultParameters()
+			params.NodeSelector = nodeSelector
+			params.Tolerations = []corev1.Toleration{toleration}
+			podSpec := getDeployment(makeKubeObjects(params)).Spec.Template.Spec
+
+			Expect(podSpec.NodeSelector).To(Equal(nodeSelector))
+			Expect(podSpec.Tolerations).To(ConsistOf(toleration))
+		})
+
+		It("should render HTTPS liveness and readiness probes against the health probe port by default", func() {
+			container := getDeployment(makeDefaultKubeObjects()).Spec.Template.Spec.Containers[0]
+
+			for _, probe := range []*corev1.Probe{container.LivenessProbe, container.ReadinessProbe} {
+				Expect(probe).NotTo(BeNil())
+				Expect(probe.HTTPGet).NotTo(BeNil())
+				Expect(probe.HTTPGet.Scheme).To(Equal(corev1.URISchemeHTTPS))
+				Expect(probe.HTTPGet.Port.IntVal).To(Equal(defaultHealthProbePort))
+			}
+			Expect(container.LivenessProbe.HTTPGet.Path).To(Equal("/healthz"))
+			Expect(container.ReadinessProbe.HTTPGet.Path).To(Equal("/readyz"))
+		})
+
+		It("should keep the default health probe port clear of the secure custom metrics port", func() {
+			Expect(defaultHealthProbePort).NotTo(Equal(containerPort))
+		})
+
+		It("should keep the --health-probe-port argument consistent with the probe definitions", func() {
+			for _, probePort := range []int32{defaultHealthProbePort, 8081} {
+				params := makeDefaultParameters()
+				params.ProbePort = probePort
+				container := getDeployment(makeKubeObjects(params)).Spec.Template.Spec.Containers[0]
+
+				Expect(container.Command).To(ContainElement(fmt.Sprintf("--health-probe-port=%d", probePort)))
+				Expect(container.LivenessProbe.HTTPGet.Port.IntVal).To(Equal(probePort))
+				Expect(container.ReadinessProbe.HTTPGet.Port.IntVal).To(Equal(probePort))
+			}
+		})
+
+		It("should render the configured probe paths and port", func() {
+			params := makeDefaultParameters()
+			params.LivenessProbePath = "/custom-healthz"
+			params.ReadinessProbePath = "/custom-readyz"
+			params.ProbePort = 8443
+			container := getDeployment(makeKubeObjects(params)).Spec.Template.Spec.Containers[0]
+
+			Expect(container.LivenessProbe.HTTPGet.Path).To(Equal("/custom-healthz"))
+			Expect(container.ReadinessProbe.HTTPGet.Path).To(Equal("/custom-readyz"))
+			Expect(container.LivenessProbe.HTTPGet.Port.IntVal).To(Equal(int32(8443)))
+			Expect(container.ReadinessProbe.HTTPGet.Port.IntVal).To(Equal(int32(8443)))
+		})
+
+		It("should not pass a --scrape-interval argument by default", func() {
+			container := getDeployment(makeDefaultKubeObjects()).Spec.Template.Spec.Containers[0]
+
+			Expect(container.Command).NotTo(ContainElement(HavePrefix("--scrape-interval=")))
+		})
+
+		It("should render a configured scrape interval", func() {
+			params := makeDefaultParameters()
+			params.ScrapeInterval = 30 * time.Second
+			container := getDeployment(makeKubeObjects(params)).Spec.Template.Spec.Containers[0]
+
+			Expect(container.Command).To(ContainElement("--scrape-interval=30s"))
+		})
+
+		It("should render no resource limits by default", func() {
+			container := getDeployment(makeDefaultKubeObjects()).Spec.Template.Spec.Containers[0]
+
+			Expect(container.Resources.Limits).To(BeEmpty())
+			Expect(container.Resources.Requests).NotTo(BeEmpty())
+		})
+
+		It("should render the configured resource limits while keeping the built-in requests", func() {
+			limits := corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("2"),
+				corev1.ResourceMemory: resource.MustParse("4Gi"),
+			}
+
+			params := makeDefaultParameters()
+			params.ResourceLimits = limits
+			container := getDeployment(makeKubeObjects(params)).Spec.Template.Spec.Containers[0]
+
+			Expect(container.Resources.Limits).To(Equal(limits))
+			Expect(container.Resources.Requests).To(HaveKey(corev1.ResourceCPU))
+			Expect(container.Resources.Requests).To(HaveKey(corev1.ResourceMemory))
+		})
+
+		It("should leave the deployment strategy empty by default", func() {
+			deployment := getDeployment(makeDefaultKubeObjects())
+
+			Expect(deployment.Spec.Strategy).To(Equal(appsv1.DeploymentStrategy{}))
+		})
+
+		It("should render a configured deployment strategy", func() {
+			params := makeDefaultParameters()
+			params.DeploymentStrategy = &appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType}
+			deployment := getDeployment(makeKubeObjects(params))
+
+			Expect(deployment.Spec.Strategy.Type).To(Equal(appsv1.RecreateDeploymentStrategyType))
+		})
+
+		It("should retain one old ReplicaSet by default", func() {
+			deployment := getDeployment(makeDefaultKubeObjects())
+
+			Expect(deployment.Spec.RevisionHistoryLimit).To(PointTo(Equal(int32(1))))
+		})
+
+		It("should render a configured revision history limit", func() {
+			params := makeDefaultParameters()
+			params.RevisionHistoryLimit = pointer.Int32(5)
+			deployment := getDeployment(makeKubeObjects(params))
+
+			Expect(deployment.Spec.RevisionHistoryLimit).To(PointTo(Equal(int32(5))))
+		})
+
+		It("should render a 30 second termination grace period and no preStop hook by default", func() {
+			podSpec := getDeployment(makeDefaultKubeObjects()).Spec.Template.Spec
+
+			Expect(podSpec.TerminationGracePeriodSeconds).To(PointTo(Equal(int64(30))))
+			Expect(podSpec.Containers[0].Lifecycle).To(BeNil())
+		})
+
+		It("should render a custom termination grace period", func() {
+			params := makeDefaultParameters()
+			params.TerminationGracePeriodSeconds = 120
+			podSpec := getDeployment(makeKubeObjects(params)).Spec.Template.Spec
+
+			Expect(podSpec.TerminationGracePeriodSeconds).To(PointTo(Equal(int64(120))))
+		})
+
+		It("should render a configured preStop sleep hook", func() {
+			params := makeDefaultParameters()
+			params.PreStopSleepSeconds = 15
+			container := getDeployment(makeKubeObjects(params)).Spec.Template.Spec.Containers[0]
+
+			Expect(container.Lifecycle).NotTo(BeNil())
+			Expect(container.Lifecycle.PreStop).NotTo(BeNil())
+			Expect(container.Lifecycle.PreStop.Exec.Command).To(Equal([]string{"sh", "-c", "sleep 15"}))
+		})
+
+		It("should render the configured topology spread constraints into the pod spec", func() {
+			constraint := corev1.TopologySpreadConstraint{
+				MaxSkew:           1,
+				TopologyKey:       corev1.LabelTopologyZone,
+				WhenUnsatisfiable: corev1.ScheduleAnyway,
+				LabelSelector:     &metav1.LabelSelector{MatchLabels: getLabels()},
+			}
+
+			params := makeDefaultParameters()
+			params.TopologySpreadConstraints = []corev1.TopologySpreadConstraint{constraint}
+			podSpec := getDeployment(makeKubeObjects(params)).Spec.Template.Spec
+
+			Expect(podSpec.TopologySpreadConstraints).To(ConsistOf(constraint))
+		})
+
+		It("should render no affinity rules for a single-replica deployment", func() {
+			podSpec := getDeployment(makeDefaultKubeObjects()).Spec.Template.Spec
+
+			Expect(podSpec.Affinity).To(BeNil())
+		})
+
+		It("should render a preferred pod anti-affinity for a multi-replica deployment", func() {
+			params := makeDefaultParameters()
+			params.Replicas = 2
+			podSpec := getDeployment(makeKubeObjects(params)).Spec.Template.Spec
+
+			Expect(podSpec.Affinity).NotTo(BeNil())
+			Expect(podSpec.Affinity.PodAntiAffinity).NotTo(BeNil())
+			terms := podSpec.Affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution
+			Expect(terms).To(HaveLen(1))
+			Expect(terms[0].PodAffinityTerm.TopologyKey).To(Equal(corev1.LabelHostname))
+			Expect(terms[0].PodAffinityTerm.LabelSelector.MatchLabels).To(
+				HaveKeyWithValue("app", "gardener-custom-metrics"))
+		})
+
+		It("should prefer an explicitly specified affinity over the derived anti-affinity", func() {
+			affinity := &corev1.Affinity{
+				NodeAffinity: &corev1.NodeAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+						NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+							MatchExpressions: []corev1.NodeSelectorRequirement{{
+								Key:      "worker.gardener.cloud/pool",
+								Operator: corev1.NodeSelectorOpIn,
+								Values:   []string{"monitoring"},
+							}},
+						}},
+					},
+				},
+			}
+
+			params := makeDefaultParameters()
+			params.Replicas = 2
+			params.Affinity = affinity
+			podSpec := getDeployment(makeKubeObjects(params)).Spec.Template.Spec
+
+			Expect(podSpec.Affinity).To(Equal(affinity))
+		})
+
+		It("should render a service with 'None' session affinity and without not-ready addresses by default", func() {
+			service := getService(makeDefaultKubeObjects())
+
+			Expect(service.Spec.SessionAffinity).To(Equal(corev1.ServiceAffinityNone))
+			Expect(service.Spec.PublishNotReadyAddresses).To(BeFalse())
+		})
+
+		It("should render the configured session affinity and not-ready address publishing into the service", func() {
+			params := makeDefaultParameters()
+			params.ServiceSessionAffinity = corev1.ServiceAffinityClientIP
+			params.ServicePublishNotReadyAddresses = true
+			service := getService(makeKubeObjects(params))
+
+			Expect(service.Spec.SessionAffinity).To(Equal(corev1.ServiceAffinityClientIP))
+			Expect(service.Spec.PublishNotReadyAddresses).To(BeTrue())
+		})
+
+		It("should render no egress NetworkPolicy by default", func() {
+			for _, object := range makeDefaultKubeObjects() {
+				Expect(object).NotTo(BeAssignableToTypeOf(&networkingv1.NetworkPolicy{}))
+			}
+		})
+
+		It("should render an egress NetworkPolicy towards the configured shoot kube-apiserver CIDRs", func() {
+			params := makeDefaultParameters()
+			params.ShootAPIServerEgressCIDRs = []string{"10.250.0.0/16", "10.251.0.0/16"}
+
+			var networkPolicy *networkingv1.NetworkPolicy
+			for _, object := range makeKubeObjects(params) {
+				if candidate, ok := object.(*networkingv1.NetworkPolicy); ok {
+					networkPolicy = candidate
+				}
+			}
+
+			Expect(networkPolicy).NotTo(BeNil())
+			Expect(networkPolicy.Spec.PodSelector.MatchLabels).To(Equal(getLabels()))
+			Expect(networkPolicy.Spec.PolicyTypes).To(ConsistOf(networkingv1.PolicyTypeEgress))
+			Expect(networkPolicy.Spec.Egress).To(HaveLen(1))
+			Expect(networkPolicy.Spec.Egress[0].To).To(HaveLen(2))
+			Expect(networkPolicy.Spec.Egress[0].To[0].IPBlock.CIDR).To(Equal("10.250.0.0/16"))
+			Expect(networkPolicy.Spec.Egress[0].To[1].IPBlock.CIDR).To(Equal("10.251.0.0/16"))
+			Expect(networkPolicy.Spec.Egress[0].Ports).To(HaveLen(1))
+			Expect(*networkPolicy.Spec.Egress[0].Ports[0].Protocol).To(Equal(corev1.ProtocolTCP))
+			Expect(networkPolicy.Spec.Egress[0].Ports[0].Port.IntVal).To(Equal(int32(443)))
+		})
+
+		It("should place the ownership marker labels on every produced object", func() {
+			for _, object := range makeDefaultKubeObjects() {
+				Expect(object.GetLabels()).To(HaveKeyWithValue("app.kubernetes.io/managed-by", "gardener"),
+					fmt.Sprintf("object %T %s", object, object.GetName()))
+				Expect(object.GetLabels()).To(HaveKeyWithValue("app.kubernetes.io/instance", "gardener-custom-metrics"),
+					fmt.Sprintf("object %T %s", object, object.GetName()))
+			}
+		})
+
+		It("should keep the ownership marker out of the immutable pod selectors", func() {
+			objects := makeDefaultKubeObjects()
+
+			Expect(getDeployment(objects).Spec.Selector.MatchLabels).To(Equal(getLabels()))
+			Expect(getService(objects).Spec.Selector).To(Equal(getLabels()))
+		})
+	})
+
+	Describe("#CleanupOrphanedObjects", func() {
+		It("should delete marked objects and spare unmarked ones", func() {
+			ctx := context.TODO()
+			seedClient := fakeclient.NewClientBuilder().WithScheme(kubernetes.SeedScheme).Build()
+
+			for _, object := range makeDefaultKubeObjects() {
+				Expect(seedClient.Create(ctx, object)).To(Succeed())
+			}
+			unmarked := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Namespace: namespace}}
+			Expect(seedClient.Create(ctx, unmarked)).To(Succeed())
+
+			Expect(CleanupOrphanedObjects(ctx, seedClient, namespace)).To(Succeed())
+
+			for _, object := range makeDefaultKubeObjects() {
+				err := seedClient.Get(ctx, client.ObjectKeyFromObject(object), object)
+				Expect(err).To(BeNotFoundError(), fmt.Sprintf("object %T %s", object, object.GetName()))
+			}
+			Expect(seedClient.Get(ctx, client.ObjectKeyFromObject(unmarked), unmarked)).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,40 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package component
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CheckImageReference performs a basic sanity check of a container image reference, so that a misconfigured image
+// fails a component's Deploy early, instead of producing a crash-looping pod. The image must not be empty and, unless
+// allowUntagged is set, must carry a tag or a digest.
+func CheckImageReference(image string, allowUntagged bool) error {
+	if image == "" {
+		return fmt.Errorf("the image reference is empty")
+	}
+
+	if allowUntagged || strings.Contains(image, "@") {
+		return nil
+	}
+
+	// The tag separator is a colon after the last slash, so that a registry port is not mistaken for a tag
+	if lastSegment := image[strings.LastIndex(image, "/")+1:]; !strings.Contains(lastSegment, ":") {
+		return fmt.Errorf("the image reference '%s' carries neither a tag, nor a digest", image)
+	}
+
+	return nil
+}
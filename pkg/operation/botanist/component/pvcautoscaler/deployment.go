@@ -0,0 +1,178 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pvcautoscaler
+
+import (
+	"fmt"
+	"strings"
+
+	v1beta1constants "github.com/gardener/gardener/pkg/apis/core/v1beta1/constants"
+	"github.com/gardener/gardener/pkg/utils"
+	versionutils "github.com/gardener/gardener/pkg/utils/version"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+)
+
+// supportsSeccompProfileField reports whether the runtime cluster understands the pod spec's seccompProfile field,
+// which was introduced in Kubernetes 1.19. On older clusters the field would be rejected, so it is left unset there.
+// If the cluster's version is unknown, support is assumed.
+func (p *pvcAutoscaler) supportsSeccompProfileField() bool {
+	if p.values.KubernetesVersion == nil {
+		return true
+	}
+
+	isSupported, err := versionutils.CompareVersions(p.values.KubernetesVersion.String(), ">=", "1.19")
+	return err == nil && isSupported
+}
+
+// deployment returns the pvc-autoscaler deployment, in its desired state. The resizableStorageClasses parameter names
+// the storage classes whose PVCs the controller is allowed to resize; PVCs bound to classes outside that list are
+// skipped by the controller.
+func (p *pvcAutoscaler) deployment(serverCertificateSecretName string, resizableStorageClasses []string) *appsv1.Deployment {
+	const (
+		serverCertificateVolumeName = Name + "-tls"
+		configVolumeName            = Name + "-config"
+	)
+
+	command := []string{
+		"./pvc-autoscaler",
+		fmt.Sprintf("--metrics-bind-address=:%d", metricsPort),
+		fmt.Sprintf("--tls-cert-file=%s/tls.crt", serverCertificateMountPath),
+		fmt.Sprintf("--tls-private-key-file=%s/tls.key", serverCertificateMountPath),
+		"--leader-election=true",
+		fmt.Sprintf("--namespace=%s", p.namespace),
+	}
+	if p.values.UseConfigFile {
+		command = append(command, fmt.Sprintf("--config=%s/%s", configFileMountPath, configFileName))
+	} else {
+		if len(resizableStorageClasses) > 0 {
+			command = append(command,
+				fmt.Sprintf("--resizable-storage-classes=%s", strings.Join(resizableStorageClasses, ",")))
+		}
+		if p.values.DryRun {
+			command = append(command, "--dry-run=true")
+		}
+		if p.values.Threshold != 0 {
+			command = append(command, fmt.Sprintf("--threshold=%d", p.values.Threshold))
+		}
+		if p.values.AnnotationOptInKey != "" {
+			command = append(command, fmt.Sprintf("--annotation-opt-in=%s", p.values.AnnotationOptInKey))
+		}
+		if p.values.AnnotationOptOutKey != "" {
+			command = append(command, fmt.Sprintf("--annotation-opt-out=%s", p.values.AnnotationOptOutKey))
+		}
+	}
+
+	podSecurityContext := &corev1.PodSecurityContext{
+		RunAsNonRoot: pointer.Bool(true),
+	}
+	if p.values.SeccompProfile && p.supportsSeccompProfileField() {
+		podSecurityContext.SeccompProfile = &corev1.SeccompProfile{
+			Type: corev1.SeccompProfileTypeRuntimeDefault,
+		}
+	}
+
+	var containerSecurityContext *corev1.SecurityContext
+	if p.values.ReadOnlyRootFilesystem {
+		containerSecurityContext = &corev1.SecurityContext{
+			ReadOnlyRootFilesystem: pointer.Bool(true),
+		}
+	}
+
+	revisionHistoryLimit := p.values.RevisionHistoryLimit
+	if revisionHistoryLimit == nil {
+		revisionHistoryLimit = pointer.Int32(1)
+	}
+
+	volumeMounts := []corev1.VolumeMount{{
+		Name:      serverCertificateVolumeName,
+		MountPath: serverCertificateMountPath,
+		ReadOnly:  true,
+	}}
+	volumes := []corev1.Volume{{
+		Name: serverCertificateVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: serverCertificateSecretName,
+			},
+		},
+	}}
+	if p.values.UseConfigFile {
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{
+			Name:      configVolumeName,
+			MountPath: configFileMountPath,
+			ReadOnly:  true,
+		})
+		volumes = append(volumes, corev1.Volume{
+			Name: configVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: Name + "-config"},
+				},
+			},
+		})
+	}
+
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      Name,
+			Namespace: p.namespace,
+			Labels:    getObjectLabels(),
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas:             pointer.Int32(1),
+			RevisionHistoryLimit: revisionHistoryLimit,
+			Selector:             &metav1.LabelSelector{MatchLabels: getLabels()},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: utils.MergeStringMaps(getObjectLabels(), map[string]string{
+						v1beta1constants.LabelNetworkPolicyToDNS:           v1beta1constants.LabelNetworkPolicyAllowed,
+						v1beta1constants.LabelNetworkPolicyToSeedAPIServer: v1beta1constants.LabelNetworkPolicyAllowed,
+						v1beta1constants.LabelNetworkPolicyFromPrometheus:  v1beta1constants.LabelNetworkPolicyAllowed,
+					}),
+				},
+				Spec: corev1.PodSpec{
+					ServiceAccountName:        Name,
+					SecurityContext:           podSecurityContext,
+					TopologySpreadConstraints: p.values.TopologySpreadConstraints,
+					Containers: []corev1.Container{{
+						Name:            Name,
+						Image:           p.values.Image,
+						ImagePullPolicy: corev1.PullIfNotPresent,
+						SecurityContext: containerSecurityContext,
+						Command:         command,
+						Ports: []corev1.ContainerPort{{
+							Name:          metricsPortName,
+							ContainerPort: metricsPort,
+							Protocol:      corev1.ProtocolTCP,
+						}},
+						Resources: corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{
+								corev1.ResourceCPU:    resource.MustParse("20m"),
+								corev1.ResourceMemory: resource.MustParse("64Mi"),
+							},
+						},
+						VolumeMounts: volumeMounts,
+					}},
+					Volumes: volumes,
+				},
+			},
+		},
+	}
+}
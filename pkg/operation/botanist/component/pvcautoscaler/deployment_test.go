@@ -0,0 +1,212 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pvcautoscaler
+
+import (
+	"github.com/Masterminds/semver"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gstruct"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+)
+
+var _ = Describe("Deployment", func() {
+	const (
+		namespace          = "garden"
+		containerImageName = "test-image:test-version"
+	)
+
+	newPvcAutoscaler := func(values Values) *pvcAutoscaler {
+		values.Image = containerImageName
+		return New(nil, namespace, nil, values).(*pvcAutoscaler)
+	}
+
+	Describe("#deployment", func() {
+		It("should not constrain the pod beyond non-root execution by default", func() {
+			deployment := newPvcAutoscaler(Values{}).deployment(serverCertificateSecretName, nil)
+
+			podSpec := deployment.Spec.Template.Spec
+			Expect(podSpec.SecurityContext.RunAsNonRoot).To(PointTo(BeTrue()))
+			Expect(podSpec.SecurityContext.SeccompProfile).To(BeNil())
+			Expect(podSpec.Containers[0].SecurityContext).To(BeNil())
+		})
+
+		It("should confine the pod to the runtime default seccomp profile if enabled", func() {
+			deployment := newPvcAutoscaler(Values{SeccompProfile: true}).deployment(serverCertificateSecretName, nil)
+
+			seccompProfile := deployment.Spec.Template.Spec.SecurityContext.SeccompProfile
+			Expect(seccompProfile).NotTo(BeNil())
+			Expect(seccompProfile.Type).To(Equal(corev1.SeccompProfileTypeRuntimeDefault))
+		})
+
+		It("should not render the seccomp profile field on clusters which predate it", func() {
+			deployment := newPvcAutoscaler(Values{SeccompProfile: true, KubernetesVersion: semver.MustParse("1.18.0")}).
+				deployment(serverCertificateSecretName, nil)
+
+			Expect(deployment.Spec.Template.Spec.SecurityContext.SeccompProfile).To(BeNil())
+		})
+
+		It("should render the seccomp profile field on clusters which support it", func() {
+			deployment := newPvcAutoscaler(Values{SeccompProfile: true, KubernetesVersion: semver.MustParse("1.19.0")}).
+				deployment(serverCertificateSecretName, nil)
+
+			Expect(deployment.Spec.Template.Spec.SecurityContext.SeccompProfile).NotTo(BeNil())
+		})
+
+		It("should deny the container writing to its root filesystem if enabled", func() {
+			deployment := newPvcAutoscaler(Values{ReadOnlyRootFilesystem: true}).deployment(serverCertificateSecretName, nil)
+
+			containerSecurityContext := deployment.Spec.Template.Spec.Containers[0].SecurityContext
+			Expect(containerSecurityContext).NotTo(BeNil())
+			Expect(containerSecurityContext.ReadOnlyRootFilesystem).To(PointTo(BeTrue()))
+		})
+
+		It("should retain one old ReplicaSet by default", func() {
+			deployment := newPvcAutoscaler(Values{}).deployment(serverCertificateSecretName, nil)
+
+			Expect(deployment.Spec.RevisionHistoryLimit).To(PointTo(Equal(int32(1))))
+		})
+
+		It("should render a configured revision history limit", func() {
+			deployment := newPvcAutoscaler(Values{RevisionHistoryLimit: pointer.Int32(5)}).
+				deployment(serverCertificateSecretName, nil)
+
+			Expect(deployment.Spec.RevisionHistoryLimit).To(PointTo(Equal(int32(5))))
+		})
+
+		It("should render no topology spread constraints by default", func() {
+			deployment := newPvcAutoscaler(Values{}).deployment(serverCertificateSecretName, nil)
+
+			Expect(deployment.Spec.Template.Spec.TopologySpreadConstraints).To(BeEmpty())
+		})
+
+		It("should render the configured topology spread constraints into the pod spec", func() {
+			constraint := corev1.TopologySpreadConstraint{
+				MaxSkew:           1,
+				TopologyKey:       corev1.LabelTopologyZone,
+				WhenUnsatisfiable: corev1.ScheduleAnyway,
+				LabelSelector:     &metav1.LabelSelector{MatchLabels: getLabels()},
+			}
+
+			deployment := newPvcAutoscaler(Values{TopologySpreadConstraints: []corev1.TopologySpreadConstraint{constraint}}).
+				deployment(serverCertificateSecretName, nil)
+
+			Expect(deployment.Spec.Template.Spec.TopologySpreadConstraints).To(ConsistOf(constraint))
+		})
+
+		It("should not render a storage class restriction if no resizable storage classes are specified", func() {
+			deployment := newPvcAutoscaler(Values{}).deployment(serverCertificateSecretName, nil)
+
+			Expect(deployment.Spec.Template.Spec.Containers[0].Command).
+				NotTo(ContainElement(HavePrefix("--resizable-storage-classes=")))
+		})
+
+		It("should render the specified resizable storage classes into the command line", func() {
+			deployment := newPvcAutoscaler(Values{}).
+				deployment(serverCertificateSecretName, []string{"default", "fast"})
+
+			Expect(deployment.Spec.Template.Spec.Containers[0].Command).
+				To(ContainElement("--resizable-storage-classes=default,fast"))
+		})
+
+		It("should not render a dry-run argument by default", func() {
+			deployment := newPvcAutoscaler(Values{}).deployment(serverCertificateSecretName, nil)
+
+			Expect(deployment.Spec.Template.Spec.Containers[0].Command).
+				NotTo(ContainElement(HavePrefix("--dry-run")))
+		})
+
+		It("should render a dry-run argument if dry-run mode is enabled", func() {
+			deployment := newPvcAutoscaler(Values{DryRun: true}).deployment(serverCertificateSecretName, nil)
+
+			Expect(deployment.Spec.Template.Spec.Containers[0].Command).To(ContainElement("--dry-run=true"))
+		})
+
+		It("should not render a threshold argument by default", func() {
+			deployment := newPvcAutoscaler(Values{}).deployment(serverCertificateSecretName, nil)
+
+			Expect(deployment.Spec.Template.Spec.Containers[0].Command).
+				NotTo(ContainElement(HavePrefix("--threshold=")))
+		})
+
+		It("should render a configured threshold into the command line", func() {
+			deployment := newPvcAutoscaler(Values{Threshold: 75}).deployment(serverCertificateSecretName, nil)
+
+			Expect(deployment.Spec.Template.Spec.Containers[0].Command).To(ContainElement("--threshold=75"))
+		})
+
+		It("should not render annotation-based PVC selection arguments by default", func() {
+			deployment := newPvcAutoscaler(Values{}).deployment(serverCertificateSecretName, nil)
+
+			Expect(deployment.Spec.Template.Spec.Containers[0].Command).
+				NotTo(ContainElement(HavePrefix("--annotation-opt-in=")))
+			Expect(deployment.Spec.Template.Spec.Containers[0].Command).
+				NotTo(ContainElement(HavePrefix("--annotation-opt-out=")))
+		})
+
+		It("should render a configured opt-in annotation key into the command line", func() {
+			deployment := newPvcAutoscaler(Values{AnnotationOptInKey: "pvc-autoscaler.gardener.cloud/enabled"}).
+				deployment(serverCertificateSecretName, nil)
+
+			Expect(deployment.Spec.Template.Spec.Containers[0].Command).
+				To(ContainElement("--annotation-opt-in=pvc-autoscaler.gardener.cloud/enabled"))
+		})
+
+		It("should render a configured opt-out annotation key into the command line", func() {
+			deployment := newPvcAutoscaler(Values{AnnotationOptOutKey: "pvc-autoscaler.gardener.cloud/disabled"}).
+				deployment(serverCertificateSecretName, nil)
+
+			Expect(deployment.Spec.Template.Spec.Containers[0].Command).
+				To(ContainElement("--annotation-opt-out=pvc-autoscaler.gardener.cloud/disabled"))
+		})
+
+		It("should replace the tuning arguments with a config argument in config-file mode", func() {
+			deployment := newPvcAutoscaler(Values{UseConfigFile: true, Threshold: 75}).
+				deployment(serverCertificateSecretName, []string{"default"})
+
+			command := deployment.Spec.Template.Spec.Containers[0].Command
+			Expect(command).To(ContainElement("--config=/etc/pvc-autoscaler/config.yaml"))
+			Expect(command).NotTo(ContainElement(HavePrefix("--threshold=")))
+			Expect(command).NotTo(ContainElement(HavePrefix("--resizable-storage-classes=")))
+		})
+
+		It("should mount the config ConfigMap in config-file mode", func() {
+			deployment := newPvcAutoscaler(Values{UseConfigFile: true}).deployment(serverCertificateSecretName, nil)
+
+			podSpec := deployment.Spec.Template.Spec
+			Expect(podSpec.Containers[0].VolumeMounts).To(ContainElement(corev1.VolumeMount{
+				Name:      "pvc-autoscaler-config",
+				MountPath: "/etc/pvc-autoscaler",
+				ReadOnly:  true,
+			}))
+			Expect(podSpec.Volumes).To(ContainElement(corev1.Volume{
+				Name: "pvc-autoscaler-config",
+				VolumeSource: corev1.VolumeSource{
+					ConfigMap: &corev1.ConfigMapVolumeSource{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "pvc-autoscaler-config"},
+					},
+				},
+			}))
+		})
+
+		It("should not mount a config ConfigMap in the default command-line mode", func() {
+			deployment := newPvcAutoscaler(Values{}).deployment(serverCertificateSecretName, nil)
+
+			Expect(deployment.Spec.Template.Spec.Volumes).To(HaveLen(1))
+		})
+	})
+})
@@ -0,0 +1,203 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pvcautoscaler
+
+import (
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	vpaautoscalingv1 "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/yaml"
+)
+
+// This file contains the builder functions for the auxiliary k8s objects which accompany the pvc-autoscaler
+// deployment.
+
+func (p *pvcAutoscaler) serviceAccount() *corev1.ServiceAccount {
+	return &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      Name,
+			Namespace: p.namespace,
+			Labels:    getObjectLabels(),
+		},
+		AutomountServiceAccountToken: pointer.Bool(true),
+	}
+}
+
+// clusterRole returns the ClusterRole which allows pvc-autoscaler to observe volumes and expand the claims bound to
+// them, cluster-wide.
+func (p *pvcAutoscaler) clusterRole() *rbacv1.ClusterRole {
+	return &rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "gardener.cloud:" + Name,
+			Labels: getObjectLabels(),
+		},
+		Rules: []rbacv1.PolicyRule{
+			{
+				APIGroups: []string{""},
+				Resources: []string{"persistentvolumeclaims"},
+				Verbs:     []string{"get", "list", "watch", "update", "patch"},
+			},
+			{
+				APIGroups: []string{""},
+				Resources: []string{"persistentvolumes"},
+				Verbs:     []string{"get", "list", "watch"},
+			},
+			{
+				APIGroups: []string{"storage.k8s.io"},
+				Resources: []string{"storageclasses"},
+				Verbs:     []string{"get", "list", "watch"},
+			},
+			{
+				APIGroups: []string{""},
+				Resources: []string{"events"},
+				Verbs:     []string{"create", "patch"},
+			},
+			{
+				APIGroups: []string{"coordination.k8s.io"},
+				Resources: []string{"leases"},
+				Verbs:     []string{"create", "get", "list", "watch", "update"},
+			},
+		},
+	}
+}
+
+func (p *pvcAutoscaler) clusterRoleBinding() *rbacv1.ClusterRoleBinding {
+	return &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "gardener.cloud:" + Name,
+			Labels: getObjectLabels(),
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     "gardener.cloud:" + Name,
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      Name,
+			Namespace: p.namespace,
+		}},
+	}
+}
+
+// scrapeTargetsAnnotationKey returns the key of the service annotation which admits scrape traffic to the metrics
+// port - the key configured in the values, or defaultScrapeTargetsAnnotationKey if none is configured.
+func (p *pvcAutoscaler) scrapeTargetsAnnotationKey() string {
+	if p.values.ScrapeTargetsAnnotationKey != "" {
+		return p.values.ScrapeTargetsAnnotationKey
+	}
+	return defaultScrapeTargetsAnnotationKey
+}
+
+func (p *pvcAutoscaler) service() *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      Name,
+			Namespace: p.namespace,
+			Labels:    getObjectLabels(),
+			Annotations: map[string]string{
+				// The port in the annotation value is derived from the exposed metrics port, so the two cannot drift apart
+				p.scrapeTargetsAnnotationKey(): fmt.Sprintf(`[{"protocol":"TCP","port":%d}]`, metricsPort),
+			},
+		},
+		Spec: corev1.ServiceSpec{
+			Type:     corev1.ServiceTypeClusterIP,
+			Selector: getLabels(),
+			Ports: []corev1.ServicePort{{
+				Name:       metricsPortName,
+				Protocol:   corev1.ProtocolTCP,
+				Port:       metricsPort,
+				TargetPort: intstr.FromInt(int(metricsPort)),
+			}},
+		},
+	}
+}
+
+// configMap returns the ConfigMap which holds the controller's tuning configuration. It is only deployed if
+// Values.UseConfigFile is enabled, in which case it replaces the individual tuning command line arguments.
+func (p *pvcAutoscaler) configMap(resizableStorageClasses []string) (*corev1.ConfigMap, error) {
+	config := map[string]interface{}{}
+	if p.values.Threshold != 0 {
+		config["threshold"] = p.values.Threshold
+	}
+	if p.values.DryRun {
+		config["dryRun"] = true
+	}
+	if len(resizableStorageClasses) > 0 {
+		config["resizableStorageClasses"] = resizableStorageClasses
+	}
+	if p.values.AnnotationOptInKey != "" {
+		config["annotationOptInKey"] = p.values.AnnotationOptInKey
+	}
+	if p.values.AnnotationOptOutKey != "" {
+		config["annotationOptOutKey"] = p.values.AnnotationOptOutKey
+	}
+
+	configYAML, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      Name + "-config",
+			Namespace: p.namespace,
+			Labels:    getObjectLabels(),
+		},
+		Data: map[string]string{configFileName: string(configYAML)},
+	}, nil
+}
+
+// vpa returns the VerticalPodAutoscaler which adjusts the resource requests of the pvc-autoscaler pod itself.
+func (p *pvcAutoscaler) vpa() *vpaautoscalingv1.VerticalPodAutoscaler {
+	var (
+		updateMode       = vpaautoscalingv1.UpdateModeAuto
+		controlledValues = vpaautoscalingv1.ContainerControlledValuesRequestsOnly
+	)
+	if p.values.VPAUpdateMode != nil {
+		updateMode = *p.values.VPAUpdateMode
+	}
+
+	return &vpaautoscalingv1.VerticalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      Name + "-vpa",
+			Namespace: p.namespace,
+			Labels:    getObjectLabels(),
+		},
+		Spec: vpaautoscalingv1.VerticalPodAutoscalerSpec{
+			TargetRef: &autoscalingv1.CrossVersionObjectReference{
+				APIVersion: appsv1.SchemeGroupVersion.String(),
+				Kind:       "Deployment",
+				Name:       Name,
+			},
+			UpdatePolicy: &vpaautoscalingv1.PodUpdatePolicy{
+				UpdateMode: &updateMode,
+			},
+			ResourcePolicy: &vpaautoscalingv1.PodResourcePolicy{
+				ContainerPolicies: []vpaautoscalingv1.ContainerResourcePolicy{{
+					ContainerName:    vpaautoscalingv1.DefaultContainerResourcePolicy,
+					ControlledValues: &controlledValues,
+				}},
+			},
+		},
+	}
+}
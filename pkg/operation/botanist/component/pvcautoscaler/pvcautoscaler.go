@@ -0,0 +1,389 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pvcautoscaler implements the pvc-autoscaler seed component: a controller which watches the fill level of
+// the persistent volumes in the seed and expands the respective PersistentVolumeClaims before they run full.
+package pvcautoscaler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	v1beta1constants "github.com/gardener/gardener/pkg/apis/core/v1beta1/constants"
+	"github.com/gardener/gardener/pkg/client/kubernetes"
+	"github.com/gardener/gardener/pkg/operation/botanist/component"
+	"github.com/gardener/gardener/pkg/utils"
+	kutil "github.com/gardener/gardener/pkg/utils/kubernetes"
+	"github.com/gardener/gardener/pkg/utils/managedresources"
+	secretutils "github.com/gardener/gardener/pkg/utils/secrets"
+	secretsmanager "github.com/gardener/gardener/pkg/utils/secrets/manager"
+	versionutils "github.com/gardener/gardener/pkg/utils/version"
+
+	"github.com/Masterminds/semver"
+	"github.com/hashicorp/go-multierror"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	vpaautoscalingv1 "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// Name is the name which serves as base for naming the various artifacts deployed by this component.
+	Name = "pvc-autoscaler"
+	// DeploymentName is the name of the deployment which runs the pvc-autoscaler pods. Exposed so that e.g. e2e tests
+	// can locate the deployment.
+	DeploymentName = Name
+
+	managedResourceName = Name
+	// serverCertificateSecretName is the name of the secret holding the TLS certificate with which pvc-autoscaler
+	// serves its metrics endpoint.
+	serverCertificateSecretName = Name + "-tls"
+
+	metricsPortName       = "metrics"
+	metricsPort     int32 = 8080
+
+	// serverCertificateMountPath is where the server TLS certificate secret is mounted inside the container.
+	serverCertificateMountPath = "/var/run/secrets/gardener.cloud/tls"
+
+	// configFileMountPath is where the controller's config ConfigMap is mounted inside the container, if config-file
+	// mode is enabled.
+	configFileMountPath = "/etc/" + Name
+	// configFileName is the name of the config file inside the controller's config ConfigMap.
+	configFileName = "config.yaml"
+
+	// defaultScrapeTargetsAnnotationKey is the key of the service annotation which admits traffic from the seed's
+	// scrape targets to the annotated ports. Seeds using a different networking convention can override the key via
+	// Values.ScrapeTargetsAnnotationKey.
+	defaultScrapeTargetsAnnotationKey = "networking.resources.gardener.cloud/from-all-seed-scrape-targets-allowed-ports"
+
+	// minimumKubernetesVersion is the lowest Kubernetes version of the runtime cluster on which the component is
+	// supported. Resizing PVCs which are in use by a pod requires the ExpandInUsePersistentVolumes feature, which is
+	// enabled by default as of that version.
+	minimumKubernetesVersion = "1.15"
+)
+
+// TimeoutWaitForManagedResource is the timeout used while waiting for the ManagedResource to become healthy or
+// deleted. Exposed for testing.
+var TimeoutWaitForManagedResource = 2 * time.Minute
+
+// Values is a set of configuration values for the pvc-autoscaler component.
+type Values struct {
+	// Image is the container image reference for pvc-autoscaler.
+	Image string
+	// KubernetesVersion is the version of the runtime cluster into which the component is deployed.
+	KubernetesVersion *semver.Version
+	// SeccompProfile specifies whether the pod is confined to the container runtime's default seccomp profile.
+	// Disabled by default for compatibility with older container runtimes.
+	SeccompProfile bool
+	// ReadOnlyRootFilesystem specifies whether the container is denied writing to its own root filesystem.
+	// Disabled by default for compatibility.
+	ReadOnlyRootFilesystem bool
+	// ScrapedMetrics is the list of metric names which prometheus scrapes from the component. If empty,
+	// standardScrapedMetrics is used.
+	ScrapedMetrics []string
+	// PrometheusLabel is the value of the 'prometheus' label on the component's ServiceMonitor, which selects the
+	// prometheus instance serving said monitor. If empty, aggregatePrometheusLabel is used.
+	PrometheusLabel string
+	// TopologySpreadConstraints, if set, governs how the pvc-autoscaler pods are spread across the seed's topology
+	// domains. Empty by default.
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint
+	// RevisionHistoryLimit is the number of old ReplicaSets retained to allow rollbacks of the pvc-autoscaler
+	// deployment. If nil, a limit of one is applied.
+	RevisionHistoryLimit *int32
+	// DryRun specifies whether pvc-autoscaler only records the resize operations it would perform, without actually
+	// patching any PersistentVolumeClaims. The controller's counter metrics remain exposed and scraped, so the
+	// would-be activity stays observable. Disabled by default.
+	DryRun bool
+	// Threshold is the volume fill level, in percent, above which pvc-autoscaler expands a PersistentVolumeClaim.
+	// Valid values are in the range [1,99]. If zero, no threshold argument is rendered and the controller's built-in
+	// default threshold applies.
+	Threshold int
+	// AnnotationOptInKey, if set, switches pvc-autoscaler to opt-in mode: only PersistentVolumeClaims which carry the
+	// specified annotation are managed. Mutually exclusive with AnnotationOptOutKey. If both are empty, the
+	// controller's default cluster-wide behavior applies.
+	AnnotationOptInKey string
+	// AnnotationOptOutKey, if set, switches pvc-autoscaler to opt-out mode: all eligible PersistentVolumeClaims are
+	// managed, except those which carry the specified annotation. Mutually exclusive with AnnotationOptInKey.
+	AnnotationOptOutKey string
+	// ScrapeTargetsAnnotationKey is the key of the service annotation which admits prometheus scrape traffic to the
+	// metrics port. If empty, the standard 'networking.resources.gardener.cloud' key is applied.
+	ScrapeTargetsAnnotationKey string
+	// VPAUpdateMode is the update mode applied by the VerticalPodAutoscaler which scales the pvc-autoscaler pod
+	// itself. Useful e.g. on clusters where VPA runs in recommendation-only mode, so that a forced 'Auto' mode does
+	// not cause pod restarts. If nil, 'Auto' is applied.
+	VPAUpdateMode *vpaautoscalingv1.UpdateMode
+	// UseConfigFile specifies whether the controller's tuning configuration is passed via a mounted ConfigMap and a
+	// single '--config' argument, instead of individual command line arguments. This keeps the args list manageable
+	// as the number of tuning knobs grows. Disabled by default.
+	UseConfigFile bool
+}
+
+// Validate returns an error aggregating all problems with the configuration held by the receiver, or nil if said
+// configuration is valid.
+func (v *Values) Validate() error {
+	var errs *multierror.Error
+
+	if err := component.CheckImageReference(v.Image, false); err != nil {
+		errs = multierror.Append(errs, err)
+	}
+	if v.Threshold != 0 && (v.Threshold < 1 || v.Threshold > 99) {
+		errs = multierror.Append(errs,
+			fmt.Errorf("the configured threshold of %d%% is outside the valid range of [1,99]", v.Threshold))
+	}
+	if v.AnnotationOptInKey != "" && v.AnnotationOptOutKey != "" {
+		errs = multierror.Append(errs,
+			fmt.Errorf("the annotation-based opt-in and opt-out modes are mutually exclusive, but both are configured"))
+	}
+
+	return errs.ErrorOrNil()
+}
+
+// WithDefaults returns a copy of the receiver in which every optional field which is left at its zero value is
+// replaced by its documented default. Fields whose zero value is meaningful on its own (e.g. Threshold, where zero
+// defers to the controller's built-in default) are left untouched. The receiver is not modified.
+func (v Values) WithDefaults() Values {
+	if v.RevisionHistoryLimit == nil {
+		v.RevisionHistoryLimit = pointer.Int32(1)
+	}
+	if v.VPAUpdateMode == nil {
+		updateMode := vpaautoscalingv1.UpdateModeAuto
+		v.VPAUpdateMode = &updateMode
+	}
+	if len(v.ScrapedMetrics) == 0 {
+		v.ScrapedMetrics = standardScrapedMetrics
+	}
+	if v.PrometheusLabel == "" {
+		v.PrometheusLabel = aggregatePrometheusLabel
+	}
+	if v.ScrapeTargetsAnnotationKey == "" {
+		v.ScrapeTargetsAnnotationKey = defaultScrapeTargetsAnnotationKey
+	}
+
+	return v
+}
+
+// New creates a new instance of DeployWaiter for the pvc-autoscaler component. The unset optional fields of the
+// specified values are filled with their documented defaults - see Values.WithDefaults.
+func New(c client.Client, namespace string, secretsManager secretsmanager.Interface, values Values) component.DeployWaiter {
+	return &pvcAutoscaler{
+		client:         c,
+		namespace:      namespace,
+		secretsManager: secretsManager,
+		values:         values.WithDefaults(),
+	}
+}
+
+type pvcAutoscaler struct {
+	client         client.Client
+	namespace      string
+	secretsManager secretsmanager.Interface
+	values         Values
+}
+
+// Deploy implements component.Deployer.
+func (p *pvcAutoscaler) Deploy(ctx context.Context) error {
+	if err := p.values.Validate(); err != nil {
+		return fmt.Errorf("refusing to deploy pvc-autoscaler in namespace '%s' with invalid configuration values: %w",
+			p.namespace, err)
+	}
+
+	if p.values.KubernetesVersion != nil {
+		isSupported, err := versionutils.CompareVersions(p.values.KubernetesVersion.String(), ">=", minimumKubernetesVersion)
+		if err != nil {
+			return fmt.Errorf("failed to compare the Kubernetes version of the cluster hosting pvc-autoscaler in namespace '%s': %w",
+				p.namespace, err)
+		}
+		if !isSupported {
+			return fmt.Errorf(
+				"refusing to deploy pvc-autoscaler in namespace '%s': the cluster's Kubernetes version %s is below the minimum supported version %s",
+				p.namespace, p.values.KubernetesVersion, minimumKubernetesVersion)
+		}
+	}
+
+	serverCertificateSecret, err := p.deployServerCertificate(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to deploy the server TLS certificate of pvc-autoscaler in namespace '%s': %w",
+			p.namespace, err)
+	}
+
+	resizableStorageClasses, err := kutil.ResizableStorageClasses(ctx, p.client)
+	if err != nil {
+		return fmt.Errorf("failed to determine the resizable storage classes for pvc-autoscaler in namespace '%s': %w",
+			p.namespace, err)
+	}
+
+	data, err := p.computeResourcesData(serverCertificateSecret.Name, resizableStorageClasses)
+	if err != nil {
+		return fmt.Errorf("failed to compute the resources of pvc-autoscaler in namespace '%s': %w", p.namespace, err)
+	}
+
+	if err := managedresources.CreateForSeed(ctx, p.client, p.namespace, managedResourceName, false, data); err != nil {
+		return fmt.Errorf("failed to deploy the ManagedResource of pvc-autoscaler in namespace '%s': %w",
+			p.namespace, err)
+	}
+
+	return nil
+}
+
+// Destroy implements component.Deployer.
+func (p *pvcAutoscaler) Destroy(ctx context.Context) error {
+	if err := managedresources.DeleteForSeed(ctx, p.client, p.namespace, managedResourceName); err != nil {
+		return err
+	}
+
+	// The server TLS certificate secret is managed by the secrets manager, but said manager's cleanup only runs as
+	// part of a flow which generates secrets. Delete the secret explicitly, so that disabling the component leaves
+	// no residue.
+	serverCertificateSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: serverCertificateSecretName, Namespace: p.namespace},
+	}
+	return client.IgnoreNotFound(p.client.Delete(ctx, serverCertificateSecret))
+}
+
+// Wait implements component.Waiter.
+func (p *pvcAutoscaler) Wait(ctx context.Context) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, TimeoutWaitForManagedResource)
+	defer cancel()
+
+	return managedresources.WaitUntilHealthy(timeoutCtx, p.client, p.namespace, managedResourceName)
+}
+
+// WaitCleanup implements component.Waiter.
+func (p *pvcAutoscaler) WaitCleanup(ctx context.Context) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, TimeoutWaitForManagedResource)
+	defer cancel()
+
+	return managedresources.WaitUntilDeleted(timeoutCtx, p.client, p.namespace, managedResourceName)
+}
+
+// deployServerCertificate generates and deploys the pvc-autoscaler metrics TLS certificate to the seed, and returns
+// the resulting secret.
+func (p *pvcAutoscaler) deployServerCertificate(ctx context.Context) (*corev1.Secret, error) {
+	return p.secretsManager.Generate(
+		ctx,
+		&secretutils.CertificateSecretConfig{
+			Name:                        serverCertificateSecretName,
+			CommonName:                  Name,
+			DNSNames:                    kutil.DNSNamesForService(Name, p.namespace),
+			CertType:                    secretutils.ServerCert,
+			SkipPublishingCACertificate: true,
+		},
+		secretsmanager.SignedByCA(v1beta1constants.SecretNameCASeed, secretsmanager.UseCurrentCA),
+		secretsmanager.Rotate(secretsmanager.InPlace))
+}
+
+// computeResourcesData serializes all k8s objects which comprise the component into a ManagedResource data map.
+func (p *pvcAutoscaler) computeResourcesData(serverCertificateSecretName string, resizableStorageClasses []string) (map[string][]byte, error) {
+	registry := managedresources.NewRegistry(kubernetes.SeedScheme, kubernetes.SeedCodec, kubernetes.SeedSerializer)
+
+	if p.values.UseConfigFile {
+		configMap, err := p.configMap(resizableStorageClasses)
+		if err != nil {
+			return nil, err
+		}
+		if err := registry.Add(configMap); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := registry.Add(
+		p.serviceAccount(),
+		p.clusterRole(),
+		p.clusterRoleBinding(),
+		p.service(),
+		p.deployment(serverCertificateSecretName, resizableStorageClasses),
+		p.vpa(),
+	); err != nil {
+		return nil, err
+	}
+
+	// The ServiceMonitor type is not part of the seed scheme, so its serialization is added to the registry directly.
+	serviceMonitorYAML, err := yaml.Marshal(p.serviceMonitor().Object)
+	if err != nil {
+		return nil, err
+	}
+	registry.AddSerialized(fmt.Sprintf("servicemonitor__%s__%s.yaml", p.namespace, Name), serviceMonitorYAML)
+
+	return registry.SerializedObjects(), nil
+}
+
+// PodLabelSelector returns a label selector which matches exactly the pvc-autoscaler pods, e.g. for listing them in
+// e2e tests.
+func PodLabelSelector() labels.Selector {
+	return labels.SelectorFromSet(getLabels())
+}
+
+// getLabels returns the labels common to all k8s objects deployed as part of pvc-autoscaler. They also serve as the
+// pod selector of the component's deployment and service, so they must remain stable across versions.
+func getLabels() map[string]string {
+	return map[string]string{
+		v1beta1constants.LabelApp:   Name,
+		v1beta1constants.GardenRole: v1beta1constants.GardenRoleSystemComponent,
+	}
+}
+
+// getOwnershipMarkerLabels returns the labels which mark an object as owned by this component, independently of the
+// ManagedResource's bookkeeping. Objects which fell out of that bookkeeping - e.g. created out of band, or left
+// behind by a crashed older version - can still be discovered via the marker, and removed by CleanupOrphanedObjects.
+func getOwnershipMarkerLabels() map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/managed-by": "gardener",
+		"app.kubernetes.io/instance":   Name,
+	}
+}
+
+// getObjectLabels returns the labels placed on the metadata of every k8s object built by this package: the common
+// component labels, plus the ownership marker.
+func getObjectLabels() map[string]string {
+	return utils.MergeStringMaps(getLabels(), getOwnershipMarkerLabels())
+}
+
+// CleanupOrphanedObjects deletes all objects in the specified namespace which carry the component's ownership marker
+// labels (see getOwnershipMarkerLabels), along with the component's marked cluster-scoped objects. It is meant for
+// removing leftovers which are no longer tracked by the component's ManagedResource, and covers the object types
+// produced by the builders in this package.
+func CleanupOrphanedObjects(ctx context.Context, seedClient client.Client, namespace string) error {
+	marker := client.MatchingLabels(getOwnershipMarkerLabels())
+
+	for _, object := range []client.Object{
+		&corev1.ServiceAccount{},
+		&corev1.Service{},
+		&corev1.ConfigMap{},
+		&appsv1.Deployment{},
+		&vpaautoscalingv1.VerticalPodAutoscaler{},
+	} {
+		if err := seedClient.DeleteAllOf(ctx, object, client.InNamespace(namespace), marker); err != nil {
+			return fmt.Errorf("failed to delete the orphaned %T objects of the %s component in namespace '%s': %w",
+				object, Name, namespace, err)
+		}
+	}
+
+	for _, object := range []client.Object{
+		&rbacv1.ClusterRole{},
+		&rbacv1.ClusterRoleBinding{},
+	} {
+		if err := seedClient.DeleteAllOf(ctx, object, marker); err != nil {
+			return fmt.Errorf("failed to delete the orphaned cluster-scoped %T objects of the %s component: %w",
+				object, Name, err)
+		}
+	}
+
+	return nil
+}
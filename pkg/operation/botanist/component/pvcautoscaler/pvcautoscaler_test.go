@@ -0,0 +1,318 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pvcautoscaler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gardener/gardener/pkg/client/kubernetes"
+	kutil "github.com/gardener/gardener/pkg/utils/kubernetes"
+	secretsmanager "github.com/gardener/gardener/pkg/utils/secrets/manager"
+	fakesecretsmanager "github.com/gardener/gardener/pkg/utils/secrets/manager/fake"
+	. "github.com/gardener/gardener/pkg/utils/test/matchers"
+
+	"github.com/Masterminds/semver"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	. "github.com/onsi/gomega/gstruct"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	vpaautoscalingv1 "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("PvcAutoscaler", func() {
+	const namespace = "garden"
+
+	var (
+		ctx        context.Context
+		seedClient client.Client
+		sm         secretsmanager.Interface
+		deployer   *pvcAutoscaler
+	)
+
+	BeforeEach(func() {
+		ctx = context.TODO()
+		seedClient = fakeclient.NewClientBuilder().WithScheme(kubernetes.SeedScheme).Build()
+		sm = fakesecretsmanager.New(seedClient, namespace)
+		deployer = New(seedClient, namespace, sm, Values{Image: "test-image:test-version"}).(*pvcAutoscaler)
+	})
+
+	Describe("#Deploy", func() {
+		It("should deploy a valid container image reference", func() {
+			Expect(deployer.Deploy(ctx)).To(Succeed())
+		})
+
+		It("should refuse to deploy an empty container image reference", func() {
+			deployer = New(seedClient, namespace, sm, Values{}).(*pvcAutoscaler)
+
+			Expect(deployer.Deploy(ctx)).To(MatchError(ContainSubstring("image")))
+		})
+
+		It("should refuse to deploy an untagged container image reference", func() {
+			deployer = New(seedClient, namespace, sm, Values{Image: "registry.example.com/test-image"}).(*pvcAutoscaler)
+
+			Expect(deployer.Deploy(ctx)).To(MatchError(ContainSubstring("neither a tag, nor a digest")))
+		})
+
+		It("should refuse to deploy an out-of-range threshold", func() {
+			deployer = New(seedClient, namespace, sm, Values{Image: "test-image:test-version", Threshold: 100}).(*pvcAutoscaler)
+
+			Expect(deployer.Deploy(ctx)).To(MatchError(ContainSubstring("threshold")))
+		})
+
+		It("should deploy on a cluster at or above the minimum supported Kubernetes version", func() {
+			deployer = New(seedClient, namespace, sm, Values{
+				Image:             "test-image:test-version",
+				KubernetesVersion: semver.MustParse("1.22.0"),
+			}).(*pvcAutoscaler)
+
+			Expect(deployer.Deploy(ctx)).To(Succeed())
+		})
+
+		It("should refuse to deploy on a cluster below the minimum supported Kubernetes version", func() {
+			deployer = New(seedClient, namespace, sm, Values{
+				Image:             "test-image:test-version",
+				KubernetesVersion: semver.MustParse("1.14.9"),
+			}).(*pvcAutoscaler)
+
+			Expect(deployer.Deploy(ctx)).To(MatchError(ContainSubstring("minimum supported version")))
+		})
+
+		It("should refuse to deploy with both annotation-based opt-in and opt-out modes configured", func() {
+			deployer = New(seedClient, namespace, sm, Values{
+				Image:               "test-image:test-version",
+				AnnotationOptInKey:  "pvc-autoscaler.gardener.cloud/enabled",
+				AnnotationOptOutKey: "pvc-autoscaler.gardener.cloud/disabled",
+			}).(*pvcAutoscaler)
+
+			Expect(deployer.Deploy(ctx)).To(MatchError(ContainSubstring("mutually exclusive")))
+		})
+	})
+
+	Describe("#Validate", func() {
+		It("should accept a valid configuration", func() {
+			values := Values{Image: "test-image:test-version", Threshold: 80}
+
+			Expect(values.Validate()).To(Succeed())
+		})
+
+		It("should report all problems of an invalid configuration", func() {
+			values := Values{
+				Threshold:           100,
+				AnnotationOptInKey:  "pvc-autoscaler.gardener.cloud/enabled",
+				AnnotationOptOutKey: "pvc-autoscaler.gardener.cloud/disabled",
+			}
+
+			err := values.Validate()
+
+			Expect(err).To(MatchError(ContainSubstring("image")))
+			Expect(err).To(MatchError(ContainSubstring("threshold")))
+			Expect(err).To(MatchError(ContainSubstring("mutually exclusive")))
+		})
+	})
+
+	Describe("#configMap", func() {
+		It("should render the tuning configuration into the config file", func() {
+			deployer = New(seedClient, namespace, sm, Values{
+				Image:     "test-image:test-version",
+				Threshold: 75,
+				DryRun:    true,
+			}).(*pvcAutoscaler)
+
+			configMap, err := deployer.configMap([]string{"default", "fast"})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configMap.Name).To(Equal("pvc-autoscaler-config"))
+			Expect(configMap.Data).To(HaveKey("config.yaml"))
+			Expect(configMap.Data["config.yaml"]).To(ContainSubstring("threshold: 75"))
+			Expect(configMap.Data["config.yaml"]).To(ContainSubstring("dryRun: true"))
+			Expect(configMap.Data["config.yaml"]).To(ContainSubstring("- default"))
+			Expect(configMap.Data["config.yaml"]).To(ContainSubstring("- fast"))
+		})
+	})
+
+	Describe("#WithDefaults", func() {
+		It("should fill each unset optional field with its documented default", func() {
+			values := Values{Image: "test-image:test-version"}.WithDefaults()
+
+			Expect(values.RevisionHistoryLimit).To(PointTo(Equal(int32(1))))
+			Expect(values.VPAUpdateMode).To(PointTo(Equal(vpaautoscalingv1.UpdateModeAuto)))
+			Expect(values.ScrapedMetrics).To(Equal(standardScrapedMetrics))
+			Expect(values.PrometheusLabel).To(Equal(aggregatePrometheusLabel))
+			Expect(values.ScrapeTargetsAnnotationKey).To(Equal(defaultScrapeTargetsAnnotationKey))
+		})
+
+		It("should preserve explicitly set fields", func() {
+			updateMode := vpaautoscalingv1.UpdateModeOff
+			values := Values{
+				Image:                      "test-image:test-version",
+				RevisionHistoryLimit:       pointer.Int32(5),
+				VPAUpdateMode:              &updateMode,
+				ScrapedMetrics:             []string{"custom_metric_total"},
+				PrometheusLabel:            "custom-prometheus",
+				ScrapeTargetsAnnotationKey: "example.com/scrape-ports",
+			}.WithDefaults()
+
+			Expect(values.RevisionHistoryLimit).To(PointTo(Equal(int32(5))))
+			Expect(values.VPAUpdateMode).To(PointTo(Equal(vpaautoscalingv1.UpdateModeOff)))
+			Expect(values.ScrapedMetrics).To(Equal([]string{"custom_metric_total"}))
+			Expect(values.PrometheusLabel).To(Equal("custom-prometheus"))
+			Expect(values.ScrapeTargetsAnnotationKey).To(Equal("example.com/scrape-ports"))
+		})
+
+		It("should leave zero values alone where zero is meaningful on its own", func() {
+			values := Values{Image: "test-image:test-version"}.WithDefaults()
+
+			Expect(values.Threshold).To(BeZero())
+			Expect(values.DryRun).To(BeFalse())
+			Expect(values.UseConfigFile).To(BeFalse())
+		})
+	})
+
+	Describe("#service", func() {
+		It("should admit scrape traffic to the exposed metrics port via the standard annotation", func() {
+			service := deployer.service()
+
+			Expect(service.Annotations).To(HaveKeyWithValue(
+				"networking.resources.gardener.cloud/from-all-seed-scrape-targets-allowed-ports",
+				`[{"protocol":"TCP","port":8080}]`))
+		})
+
+		It("should render the scrape traffic annotation under a configured key", func() {
+			deployer = New(seedClient, namespace, sm, Values{
+				Image:                      "test-image:test-version",
+				ScrapeTargetsAnnotationKey: "example.com/scrape-ports",
+			}).(*pvcAutoscaler)
+
+			service := deployer.service()
+
+			Expect(service.Annotations).To(HaveKeyWithValue("example.com/scrape-ports", `[{"protocol":"TCP","port":8080}]`))
+			Expect(service.Annotations).NotTo(HaveKey(
+				"networking.resources.gardener.cloud/from-all-seed-scrape-targets-allowed-ports"))
+		})
+	})
+
+	Describe("#vpa", func() {
+		It("should apply the 'Auto' update mode by default", func() {
+			vpa := deployer.vpa()
+
+			Expect(vpa.Spec.UpdatePolicy.UpdateMode).To(PointTo(Equal(vpaautoscalingv1.UpdateModeAuto)))
+		})
+
+		It("should render a configured update mode", func() {
+			for _, updateMode := range []vpaautoscalingv1.UpdateMode{
+				vpaautoscalingv1.UpdateModeAuto,
+				vpaautoscalingv1.UpdateModeInitial,
+				vpaautoscalingv1.UpdateModeOff,
+			} {
+				updateMode := updateMode
+				deployer = New(seedClient, namespace, sm, Values{
+					Image:         "test-image:test-version",
+					VPAUpdateMode: &updateMode,
+				}).(*pvcAutoscaler)
+
+				vpa := deployer.vpa()
+
+				Expect(vpa.Spec.UpdatePolicy.UpdateMode).To(PointTo(Equal(updateMode)))
+			}
+		})
+	})
+
+	Describe("#getObjectLabels", func() {
+		It("should place the ownership marker labels on every built object", func() {
+			configMap, err := deployer.configMap(nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			for _, object := range []client.Object{
+				deployer.serviceAccount(),
+				deployer.clusterRole(),
+				deployer.clusterRoleBinding(),
+				deployer.service(),
+				configMap,
+				deployer.vpa(),
+				deployer.deployment(serverCertificateSecretName, nil),
+				deployer.serviceMonitor(),
+			} {
+				Expect(object.GetLabels()).To(HaveKeyWithValue("app.kubernetes.io/managed-by", "gardener"),
+					fmt.Sprintf("object %T %s", object, object.GetName()))
+				Expect(object.GetLabels()).To(HaveKeyWithValue("app.kubernetes.io/instance", Name),
+					fmt.Sprintf("object %T %s", object, object.GetName()))
+			}
+		})
+
+		It("should keep the ownership marker out of the immutable pod selectors", func() {
+			Expect(deployer.deployment(serverCertificateSecretName, nil).Spec.Selector.MatchLabels).To(Equal(getLabels()))
+			Expect(deployer.service().Spec.Selector).To(Equal(getLabels()))
+		})
+	})
+
+	Describe("#CleanupOrphanedObjects", func() {
+		It("should delete marked objects and spare unmarked ones", func() {
+			marked := []client.Object{
+				deployer.serviceAccount(),
+				deployer.clusterRole(),
+				deployer.clusterRoleBinding(),
+				deployer.service(),
+				deployer.vpa(),
+				deployer.deployment(serverCertificateSecretName, nil),
+			}
+			for _, object := range marked {
+				Expect(seedClient.Create(ctx, object)).To(Succeed())
+			}
+			unmarked := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Namespace: namespace}}
+			Expect(seedClient.Create(ctx, unmarked)).To(Succeed())
+
+			Expect(CleanupOrphanedObjects(ctx, seedClient, namespace)).To(Succeed())
+
+			for _, object := range marked {
+				err := seedClient.Get(ctx, client.ObjectKeyFromObject(object), object)
+				Expect(err).To(BeNotFoundError(), fmt.Sprintf("object %T %s", object, object.GetName()))
+			}
+			Expect(seedClient.Get(ctx, client.ObjectKeyFromObject(unmarked), unmarked)).To(Succeed())
+		})
+	})
+
+	Describe("#PodLabelSelector", func() {
+		It("should match the labels of the rendered deployment's pods", func() {
+			deployment := deployer.deployment(serverCertificateSecretName, nil)
+
+			Expect(deployment.Name).To(Equal(DeploymentName))
+			Expect(PodLabelSelector().Matches(labels.Set(deployment.Spec.Template.Labels))).To(BeTrue())
+		})
+	})
+
+	Describe("#Destroy", func() {
+		It("should delete the server TLS certificate secret", func() {
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: serverCertificateSecretName, Namespace: namespace},
+			}
+			Expect(seedClient.Create(ctx, secret)).To(Succeed())
+
+			Expect(deployer.Destroy(ctx)).To(Succeed())
+
+			err := seedClient.Get(ctx, kutil.Key(namespace, serverCertificateSecretName), &corev1.Secret{})
+			Expect(err).To(BeNotFoundError())
+		})
+
+		It("should succeed if the server TLS certificate secret is missing", func() {
+			Expect(deployer.Destroy(ctx)).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,99 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pvcautoscaler
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// aggregatePrometheusLabel is the value of the 'prometheus' label by which the seed's aggregate prometheus instance
+// discovers the ServiceMonitor objects it serves.
+const aggregatePrometheusLabel = "aggregate-prometheus"
+
+// standardScrapedMetrics are the names of the pvc-autoscaler metrics which are scraped into the seed's aggregate
+// prometheus instance. All other metrics exposed by the component are dropped at scrape time.
+var standardScrapedMetrics = []string{
+	"pvc_autoscaler_resized_total",
+	"pvc_autoscaler_threshold_reached_total",
+	"pvc_autoscaler_errors_total",
+	"pvc_autoscaler_skipped_total",
+}
+
+// prometheusLabel returns the value of the 'prometheus' label on the component's ServiceMonitor - the value
+// configured in the values, or aggregatePrometheusLabel if none is configured.
+func (p *pvcAutoscaler) prometheusLabel() string {
+	if p.values.PrometheusLabel != "" {
+		return p.values.PrometheusLabel
+	}
+	return aggregatePrometheusLabel
+}
+
+// scrapedMetrics returns the names of the metrics which prometheus scrapes from the component - the list configured
+// in the values, or standardScrapedMetrics if none is configured.
+func (p *pvcAutoscaler) scrapedMetrics() []string {
+	if len(p.values.ScrapedMetrics) > 0 {
+		return p.values.ScrapedMetrics
+	}
+	return standardScrapedMetrics
+}
+
+// serviceMonitor returns the ServiceMonitor through which the seed's aggregate prometheus instance scrapes the
+// pvc-autoscaler metrics endpoint. The prometheus-operator API types are not part of the seed scheme, so the object
+// is represented as unstructured data.
+func (p *pvcAutoscaler) serviceMonitor() *unstructured.Unstructured {
+	labels := map[string]interface{}{"prometheus": p.prometheusLabel()}
+	for key, value := range getObjectLabels() {
+		labels[key] = value
+	}
+
+	matchLabels := map[string]interface{}{}
+	for key, value := range getLabels() {
+		matchLabels[key] = value
+	}
+
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "monitoring.coreos.com/v1",
+		"kind":       "ServiceMonitor",
+		"metadata": map[string]interface{}{
+			"name":      Name,
+			"namespace": p.namespace,
+			"labels":    labels,
+		},
+		"spec": map[string]interface{}{
+			"selector": map[string]interface{}{
+				"matchLabels": matchLabels,
+			},
+			"endpoints": []interface{}{
+				map[string]interface{}{
+					"port":   metricsPortName,
+					"scheme": "https",
+					"tlsConfig": map[string]interface{}{
+						// The metrics endpoint is scraped via pod IP, which the server certificate does not cover
+						"insecureSkipVerify": true,
+					},
+					"metricRelabelings": []interface{}{
+						map[string]interface{}{
+							"sourceLabels": []interface{}{"__name__"},
+							"action":       "keep",
+							"regex":        "^(" + strings.Join(p.scrapedMetrics(), "|") + ")$",
+						},
+					},
+				},
+			},
+		},
+	}}
+}
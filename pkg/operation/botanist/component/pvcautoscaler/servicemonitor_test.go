@@ -0,0 +1,72 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pvcautoscaler
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var _ = Describe("ServiceMonitor", func() {
+	const namespace = "garden"
+
+	newPvcAutoscalerWithValues := func(values Values) *pvcAutoscaler {
+		values.Image = "test-image:test-version"
+		return New(nil, namespace, nil, values).(*pvcAutoscaler)
+	}
+
+	// Returns the metric name filter regex from the specified ServiceMonitor object.
+	getMetricNameRegex := func(serviceMonitor *unstructured.Unstructured) string {
+		endpoints, found, err := unstructured.NestedSlice(serviceMonitor.Object, "spec", "endpoints")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(found).To(BeTrue())
+
+		relabelings := endpoints[0].(map[string]interface{})["metricRelabelings"].([]interface{})
+		return relabelings[0].(map[string]interface{})["regex"].(string)
+	}
+
+	Describe("#serviceMonitor", func() {
+		It("should scrape the standard metrics by default", func() {
+			serviceMonitor := newPvcAutoscalerWithValues(Values{}).serviceMonitor()
+
+			regex := getMetricNameRegex(serviceMonitor)
+			for _, metricName := range standardScrapedMetrics {
+				Expect(regex).To(ContainSubstring(metricName))
+			}
+		})
+
+		It("should bind to the aggregate prometheus instance by default", func() {
+			serviceMonitor := newPvcAutoscalerWithValues(Values{}).serviceMonitor()
+
+			Expect(serviceMonitor.GetLabels()).To(HaveKeyWithValue("prometheus", aggregatePrometheusLabel))
+		})
+
+		It("should bind to a custom prometheus instance if one is configured", func() {
+			serviceMonitor := newPvcAutoscalerWithValues(Values{PrometheusLabel: "custom-prometheus"}).serviceMonitor()
+
+			Expect(serviceMonitor.GetLabels()).To(HaveKeyWithValue("prometheus", "custom-prometheus"))
+		})
+
+		It("should scrape a custom metrics list if one is configured", func() {
+			serviceMonitor := newPvcAutoscalerWithValues(Values{
+				ScrapedMetrics: []string{"pvc_autoscaler_resize_latency_seconds", "pvc_autoscaler_resized_total"},
+			}).serviceMonitor()
+
+			regex := getMetricNameRegex(serviceMonitor)
+			Expect(regex).To(Equal("^(pvc_autoscaler_resize_latency_seconds|pvc_autoscaler_resized_total)$"))
+		})
+	})
+})
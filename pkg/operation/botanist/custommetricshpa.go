@@ -0,0 +1,64 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package botanist
+
+import (
+	"context"
+
+	"github.com/gardener/gardener/pkg/features"
+	gardenletfeatures "github.com/gardener/gardener/pkg/gardenlet/features"
+	"github.com/gardener/gardener/pkg/operation/botanist/component/bipa"
+	versionutils "github.com/gardener/gardener/pkg/utils/version"
+)
+
+const (
+	// CustomMetricsHPAMinReplicaCount is the default lower bound of the kube-apiserver's horizontal scaling range.
+	CustomMetricsHPAMinReplicaCount = 1
+	// CustomMetricsHPAMaxReplicaCount is the default upper bound of the kube-apiserver's horizontal scaling range.
+	// Exported so that e2e tests asserting scaling behavior can derive their expectations from the actually
+	// configured range instead of duplicating it.
+	CustomMetricsHPAMaxReplicaCount = 4
+)
+
+// DefaultCustomMetricsHPA returns a deployer for the custom-metrics-based kube-apiserver autoscaler (bipa).
+func (b *Botanist) DefaultCustomMetricsHPA() bipa.Interface {
+	return bipa.New(
+		b.K8sSeedClient.Client(),
+		b.Shoot.SeedNamespace,
+		nil,
+		bipa.DesiredStateParameters{
+			IsEnabled:       b.IsCustomMetricsHPAEnabled(),
+			MinReplicaCount: CustomMetricsHPAMinReplicaCount,
+			MaxReplicaCount: CustomMetricsHPAMaxReplicaCount,
+		},
+	)
+}
+
+// IsCustomMetricsHPAEnabled determines whether the custom-metrics-based kube-apiserver autoscaler is enabled for the
+// shoot. It requires the GardenerCustomMetrics feature gate, as bipa scales on the metrics provided by that
+// component, and a shoot Kubernetes version which supports the required HPA behavior.
+func (b *Botanist) IsCustomMetricsHPAEnabled() bool {
+	if !gardenletfeatures.FeatureGate.Enabled(features.GardenerCustomMetrics) {
+		return false
+	}
+
+	return versionutils.ConstraintK8sGreaterEqual121.Check(b.Shoot.KubernetesVersion)
+}
+
+// DeployCustomMetricsHPA reconciles the custom-metrics-based kube-apiserver autoscaler in the seed. If the
+// autoscaler is disabled for the shoot, this removes its objects from the seed.
+func (b *Botanist) DeployCustomMetricsHPA(ctx context.Context) error {
+	return b.Shoot.Components.ControlPlane.CustomMetricsHPA.Deploy(ctx)
+}
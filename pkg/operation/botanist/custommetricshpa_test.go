@@ -0,0 +1,81 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package botanist_test
+
+import (
+	mockkubernetes "github.com/gardener/gardener/pkg/client/kubernetes/mock"
+	"github.com/gardener/gardener/pkg/features"
+	gardenletfeatures "github.com/gardener/gardener/pkg/gardenlet/features"
+	"github.com/gardener/gardener/pkg/operation"
+	. "github.com/gardener/gardener/pkg/operation/botanist"
+	shootpkg "github.com/gardener/gardener/pkg/operation/shoot"
+	"github.com/gardener/gardener/pkg/utils/test"
+
+	"github.com/Masterminds/semver"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CustomMetricsHPA", func() {
+	var (
+		ctrl     *gomock.Controller
+		botanist *Botanist
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		botanist = &Botanist{Operation: &operation.Operation{}}
+		botanist.Shoot = &shootpkg.Shoot{
+			SeedNamespace:     "shoot--foo--bar",
+			KubernetesVersion: semver.MustParse("1.23.6"),
+		}
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	Describe("#IsCustomMetricsHPAEnabled", func() {
+		It("should return false if the GardenerCustomMetrics feature gate is disabled", func() {
+			defer test.WithFeatureGate(gardenletfeatures.FeatureGate, features.GardenerCustomMetrics, false)()
+
+			Expect(botanist.IsCustomMetricsHPAEnabled()).To(BeFalse())
+		})
+
+		It("should return true for a sufficiently recent shoot if the feature gate is enabled", func() {
+			defer test.WithFeatureGate(gardenletfeatures.FeatureGate, features.GardenerCustomMetrics, true)()
+
+			Expect(botanist.IsCustomMetricsHPAEnabled()).To(BeTrue())
+		})
+
+		It("should return false for a shoot with an unsupported Kubernetes version", func() {
+			defer test.WithFeatureGate(gardenletfeatures.FeatureGate, features.GardenerCustomMetrics, true)()
+			botanist.Shoot.KubernetesVersion = semver.MustParse("1.20.11")
+
+			Expect(botanist.IsCustomMetricsHPAEnabled()).To(BeFalse())
+		})
+	})
+
+	Describe("#DefaultCustomMetricsHPA", func() {
+		It("should create a bipa deployer", func() {
+			kubernetesClient := mockkubernetes.NewMockInterface(ctrl)
+			kubernetesClient.EXPECT().Client()
+			botanist.K8sSeedClient = kubernetesClient
+
+			Expect(botanist.DefaultCustomMetricsHPA()).NotTo(BeNil())
+		})
+	})
+})
@@ -0,0 +1,61 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package botanist
+
+import (
+	"context"
+
+	v1beta1constants "github.com/gardener/gardener/pkg/apis/core/v1beta1/constants"
+	"github.com/gardener/gardener/pkg/features"
+	gardenletfeatures "github.com/gardener/gardener/pkg/gardenlet/features"
+	"github.com/gardener/gardener/pkg/operation/botanist/component"
+	"github.com/gardener/gardener/pkg/operation/botanist/component/gardenercustommetrics"
+	"github.com/gardener/gardener/pkg/utils/images"
+	"github.com/gardener/gardener/pkg/utils/imagevector"
+
+	"github.com/Masterminds/semver"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// DefaultGardenerCustomMetrics returns a deployer for the gardener-custom-metrics component (aka GCMx).
+func (b *Botanist) DefaultGardenerCustomMetrics() (component.DeployWaiter, error) {
+	image, err := b.ImageVector.FindImage(images.ImageNameGardenerCustomMetrics, imagevector.RuntimeVersion(b.SeedVersion()), imagevector.TargetVersion(b.SeedVersion()))
+	if err != nil {
+		return nil, err
+	}
+
+	// The seed version is only used to gate version-specific behavior - if it cannot be determined, GCMx falls
+	// back to the behavior for recent seed versions.
+	seedVersion, err := semver.NewVersion(b.SeedVersion())
+	if err != nil {
+		seedVersion = nil
+	}
+
+	return gardenercustommetrics.NewGardenerCustomMetrics(
+		v1beta1constants.GardenNamespace,
+		image.String(),
+		b.K8sSeedClient.Client(),
+		b.SecretsManager,
+		gardenercustommetrics.WithEnabled(gardenletfeatures.FeatureGate.Enabled(features.GardenerCustomMetrics)),
+		gardenercustommetrics.WithKubernetesVersion(seedVersion),
+		gardenercustommetrics.WithLogger(logf.Log.WithName("gardener-custom-metrics")),
+	), nil
+}
+
+// DeployGardenerCustomMetrics reconciles the gardener-custom-metrics component in the seed. If the component is
+// disabled, this removes it from the seed.
+func (b *Botanist) DeployGardenerCustomMetrics(ctx context.Context) error {
+	return b.Shoot.Components.ControlPlane.GardenerCustomMetrics.Deploy(ctx)
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package botanist_test
+
+import (
+	mockkubernetes "github.com/gardener/gardener/pkg/client/kubernetes/mock"
+	"github.com/gardener/gardener/pkg/operation"
+	. "github.com/gardener/gardener/pkg/operation/botanist"
+	"github.com/gardener/gardener/pkg/utils/imagevector"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GardenerCustomMetrics", func() {
+	var (
+		ctrl     *gomock.Controller
+		botanist *Botanist
+	)
+
+	BeforeEach(func() {
+		ctrl = gomock.NewController(GinkgoT())
+		botanist = &Botanist{Operation: &operation.Operation{}}
+	})
+
+	AfterEach(func() {
+		ctrl.Finish()
+	})
+
+	Describe("#DefaultGardenerCustomMetrics", func() {
+		var kubernetesClient *mockkubernetes.MockInterface
+
+		BeforeEach(func() {
+			kubernetesClient = mockkubernetes.NewMockInterface(ctrl)
+			kubernetesClient.EXPECT().Version().AnyTimes()
+
+			botanist.K8sSeedClient = kubernetesClient
+		})
+
+		It("should successfully create a gardener-custom-metrics deployer", func() {
+			kubernetesClient.EXPECT().Client()
+			botanist.ImageVector = imagevector.ImageVector{{Name: "gardener-custom-metrics"}}
+
+			gardenerCustomMetrics, err := botanist.DefaultGardenerCustomMetrics()
+			Expect(gardenerCustomMetrics).NotTo(BeNil())
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should return an error because the image cannot be found", func() {
+			botanist.ImageVector = imagevector.ImageVector{}
+
+			gardenerCustomMetrics, err := botanist.DefaultGardenerCustomMetrics()
+			Expect(gardenerCustomMetrics).To(BeNil())
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
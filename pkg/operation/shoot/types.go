@@ -24,6 +24,7 @@ import (
 	gardencorev1beta1 "github.com/gardener/gardener/pkg/apis/core/v1beta1"
 	"github.com/gardener/gardener/pkg/operation/botanist/component"
 	"github.com/gardener/gardener/pkg/operation/botanist/component/backupentry"
+	"github.com/gardener/gardener/pkg/operation/botanist/component/bipa"
 	"github.com/gardener/gardener/pkg/operation/botanist/component/clusterautoscaler"
 	"github.com/gardener/gardener/pkg/operation/botanist/component/clusteridentity"
 	"github.com/gardener/gardener/pkg/operation/botanist/component/coredns"
@@ -110,9 +111,11 @@ type Components struct {
 // ControlPlane contains references to K8S control plane components.
 type ControlPlane struct {
 	ClusterAutoscaler     clusterautoscaler.Interface
+	CustomMetricsHPA      bipa.Interface
 	EtcdMain              etcd.Interface
 	EtcdEvents            etcd.Interface
 	EtcdCopyBackupsTask   etcdcopybackupstask.Interface
+	GardenerCustomMetrics component.DeployWaiter
 	KubeAPIServerService  component.DeployWaiter
 	KubeAPIServerSNI      component.DeployWaiter
 	KubeAPIServerSNIPhase component.Phase
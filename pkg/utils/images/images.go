@@ -55,6 +55,8 @@ const (
 	ImageNameGardenerResourceManager = "gardener-resource-manager"
 	// ImageNameGardenerSeedAdmissionController is a constant for an image in the image vector with name 'gardener-seed-admission-controller'.
 	ImageNameGardenerSeedAdmissionController = "gardener-seed-admission-controller"
+	// ImageNameGardenerCustomMetrics is a constant for an image in the image vector with name 'gardener-custom-metrics'.
+	ImageNameGardenerCustomMetrics = "gardener-custom-metrics"
 	// ImageNameGardenlet is a constant for an image in the image vector with name 'gardenlet'.
 	ImageNameGardenlet = "gardenlet"
 	// ImageNameGrafana is a constant for an image in the image vector with name 'grafana'.
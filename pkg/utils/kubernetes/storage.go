@@ -0,0 +1,90 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// defaultStorageClassAnnotation is the annotation marking a storage class as the cluster's default.
+	defaultStorageClassAnnotation = "storageclass.kubernetes.io/is-default-class"
+	// defaultStorageClassBetaAnnotation is the legacy variant of defaultStorageClassAnnotation, still honored by
+	// Kubernetes.
+	defaultStorageClassBetaAnnotation = "storageclass.beta.kubernetes.io/is-default-class"
+)
+
+// isDefaultStorageClass reports whether the given storage class is annotated as the cluster's default.
+func isDefaultStorageClass(storageClass *storagev1.StorageClass) bool {
+	return storageClass.Annotations[defaultStorageClassAnnotation] == "true" ||
+		storageClass.Annotations[defaultStorageClassBetaAnnotation] == "true"
+}
+
+// IsDefaultStorageClassResizable reports whether the cluster's default storage class allows volume expansion. It
+// returns false if the cluster has no default storage class. Kubernetes does not prevent multiple storage classes
+// from being marked as default, and which of them provisions a given PVC is not deterministic; in that case the
+// function reports true only if all of the default classes allow volume expansion.
+func IsDefaultStorageClassResizable(ctx context.Context, c client.Client) (bool, error) {
+	// The default annotation is not a label, so the list cannot be narrowed server-side; bail out early at least if
+	// the caller is no longer interested in the answer.
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	storageClassList := &storagev1.StorageClassList{}
+	if err := c.List(ctx, storageClassList); err != nil {
+		return false, fmt.Errorf(
+			"failed to list the cluster's storage classes while checking the default storage class for resizability: %w",
+			err)
+	}
+
+	hasDefault := false
+	for _, storageClass := range storageClassList.Items {
+		if !isDefaultStorageClass(&storageClass) {
+			continue
+		}
+		hasDefault = true
+		if !pointer.BoolDeref(storageClass.AllowVolumeExpansion, false) {
+			return false, nil
+		}
+	}
+
+	return hasDefault, nil
+}
+
+// ResizableStorageClasses returns the names of all storage classes in the cluster which allow volume expansion,
+// sorted alphabetically.
+func ResizableStorageClasses(ctx context.Context, c client.Client) ([]string, error) {
+	storageClassList := &storagev1.StorageClassList{}
+	if err := c.List(ctx, storageClassList); err != nil {
+		return nil, fmt.Errorf("failed to list the cluster's storage classes: %w", err)
+	}
+
+	var names []string
+	for _, storageClass := range storageClassList.Items {
+		if pointer.BoolDeref(storageClass.AllowVolumeExpansion, false) {
+			names = append(names, storageClass.Name)
+		}
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes_test
+
+import (
+	"context"
+
+	. "github.com/gardener/gardener/pkg/utils/kubernetes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Storage", func() {
+	var (
+		ctx        context.Context
+		fakeClient client.Client
+	)
+
+	// Creates a storage class with the specified name and volume expansion setting. A nil allowVolumeExpansion leaves
+	// the field unset.
+	createStorageClass := func(name string, allowVolumeExpansion *bool) {
+		storageClass := &storagev1.StorageClass{
+			ObjectMeta:           metav1.ObjectMeta{Name: name},
+			Provisioner:          "test-provisioner",
+			AllowVolumeExpansion: allowVolumeExpansion,
+		}
+		ExpectWithOffset(1, fakeClient.Create(ctx, storageClass)).To(Succeed())
+	}
+
+	// Creates a storage class annotated as the cluster's default, with the specified volume expansion setting.
+	createDefaultStorageClass := func(name string, allowVolumeExpansion *bool) {
+		storageClass := &storagev1.StorageClass{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Annotations: map[string]string{"storageclass.kubernetes.io/is-default-class": "true"},
+			},
+			Provisioner:          "test-provisioner",
+			AllowVolumeExpansion: allowVolumeExpansion,
+		}
+		ExpectWithOffset(1, fakeClient.Create(ctx, storageClass)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		ctx = context.TODO()
+		scheme := runtime.NewScheme()
+		Expect(storagev1.AddToScheme(scheme)).To(Succeed())
+		fakeClient = fakeclient.NewClientBuilder().WithScheme(scheme).Build()
+	})
+
+	Describe("#IsDefaultStorageClassResizable", func() {
+		It("should return false if the cluster has no default storage class", func() {
+			createStorageClass("resizable-but-not-default", pointer.Bool(true))
+
+			isResizable, err := IsDefaultStorageClassResizable(ctx, fakeClient)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(isResizable).To(BeFalse())
+		})
+
+		It("should return false if the single default storage class is not resizable", func() {
+			createDefaultStorageClass("default", pointer.Bool(false))
+
+			isResizable, err := IsDefaultStorageClassResizable(ctx, fakeClient)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(isResizable).To(BeFalse())
+		})
+
+		It("should abort if the context is cancelled", func() {
+			cancelledCtx, cancel := context.WithCancel(ctx)
+			cancel()
+
+			_, err := IsDefaultStorageClassResizable(cancelledCtx, fakeClient)
+
+			Expect(err).To(MatchError(context.Canceled))
+		})
+
+		It("should return true if the single default storage class is resizable", func() {
+			createStorageClass("fixed", pointer.Bool(false))
+			createDefaultStorageClass("default", pointer.Bool(true))
+
+			isResizable, err := IsDefaultStorageClassResizable(ctx, fakeClient)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(isResizable).To(BeTrue())
+		})
+
+		It("should return true if all of multiple default storage classes are resizable", func() {
+			createDefaultStorageClass("default1", pointer.Bool(true))
+			createDefaultStorageClass("default2", pointer.Bool(true))
+
+			isResizable, err := IsDefaultStorageClassResizable(ctx, fakeClient)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(isResizable).To(BeTrue())
+		})
+
+		It("should return false if one of multiple default storage classes is not resizable", func() {
+			createDefaultStorageClass("default1", pointer.Bool(true))
+			createDefaultStorageClass("default2", pointer.Bool(false))
+
+			isResizable, err := IsDefaultStorageClassResizable(ctx, fakeClient)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(isResizable).To(BeFalse())
+		})
+	})
+
+	Describe("#ResizableStorageClasses", func() {
+		It("should return only the names of the storage classes which allow volume expansion", func() {
+			createStorageClass("resizable", pointer.Bool(true))
+			createStorageClass("fixed", pointer.Bool(false))
+			createStorageClass("unspecified", nil)
+			createStorageClass("also-resizable", pointer.Bool(true))
+
+			names, err := ResizableStorageClasses(ctx, fakeClient)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(names).To(Equal([]string{"also-resizable", "resizable"}))
+		})
+
+		It("should return an empty list if no storage classes exist", func() {
+			names, err := ResizableStorageClasses(ctx, fakeClient)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(names).To(BeEmpty())
+		})
+	})
+})
@@ -32,6 +32,7 @@ import (
 	"github.com/gardener/gardener/pkg/utils/managedresources/builder"
 	"github.com/gardener/gardener/pkg/utils/retry"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/utils/pointer"
@@ -56,6 +57,30 @@ func SecretName(name string, withPrefix bool) string {
 	return name
 }
 
+// GetSecret returns the corev1.Secret which backs the resourcesv1alpha1.ManagedResource with the given name, resolved
+// by following the managed resource's secret reference. It saves callers from relying on the naming convention
+// applied by SecretName, or on the position of the reference in the managed resource's spec.
+func GetSecret(ctx context.Context, c client.Client, namespace, name string) (*corev1.Secret, error) {
+	managedResource := &resourcesv1alpha1.ManagedResource{}
+	if err := c.Get(ctx, kutil.Key(namespace, name), managedResource); err != nil {
+		return nil, fmt.Errorf("failed to retrieve the managed resource '%s/%s': %w", namespace, name, err)
+	}
+
+	if len(managedResource.Spec.SecretRefs) != 1 {
+		return nil, fmt.Errorf("expected the managed resource '%s/%s' to reference exactly one secret, but it references %d",
+			namespace, name, len(managedResource.Spec.SecretRefs))
+	}
+
+	secretName := managedResource.Spec.SecretRefs[0].Name
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, kutil.Key(namespace, secretName), secret); err != nil {
+		return nil, fmt.Errorf("failed to retrieve the secret '%s/%s' referenced by the managed resource '%s/%s': %w",
+			namespace, secretName, namespace, name, err)
+	}
+
+	return secret, nil
+}
+
 // New initiates a new ManagedResource object which can be reconciled.
 func New(client client.Client, namespace, name, class string, keepObjects *bool, labels, injectedLabels map[string]string, forceOverwriteAnnotations *bool) *builder.ManagedResource {
 	mr := builder.NewManagedResource(client).
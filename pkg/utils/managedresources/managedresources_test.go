@@ -120,6 +120,50 @@ var _ = Describe("managedresources", func() {
 		})
 	})
 
+	Describe("#GetSecret", func() {
+		It("should return the error of the managed resource retrieval", func() {
+			c.EXPECT().Get(ctx, kutil.Key(namespace, name), gomock.AssignableToTypeOf(&resourcesv1alpha1.ManagedResource{})).Return(fakeErr)
+
+			secret, err := GetSecret(ctx, c, namespace, name)
+
+			Expect(secret).To(BeNil())
+			Expect(err).To(MatchError(ContainSubstring("fake")))
+		})
+
+		It("should fail for a managed resource which references no secret", func() {
+			c.EXPECT().Get(ctx, kutil.Key(namespace, name), gomock.AssignableToTypeOf(&resourcesv1alpha1.ManagedResource{}))
+
+			secret, err := GetSecret(ctx, c, namespace, name)
+
+			Expect(secret).To(BeNil())
+			Expect(err).To(MatchError(ContainSubstring("exactly one secret")))
+		})
+
+		It("should resolve the secret referenced by a reconciled managed resource", func() {
+			data := map[string][]byte{"object.yaml": []byte("rendered")}
+			gomock.InOrder(
+				c.EXPECT().Get(ctx, kutil.Key(namespace, name), gomock.AssignableToTypeOf(&resourcesv1alpha1.ManagedResource{})).DoAndReturn(
+					func(_ context.Context, _ client.ObjectKey, mr *resourcesv1alpha1.ManagedResource) error {
+						mr.Spec.SecretRefs = []corev1.LocalObjectReference{{Name: "managedresource-" + name}}
+						return nil
+					}),
+				c.EXPECT().Get(ctx, kutil.Key(namespace, "managedresource-"+name), gomock.AssignableToTypeOf(&corev1.Secret{})).DoAndReturn(
+					func(_ context.Context, key client.ObjectKey, secret *corev1.Secret) error {
+						secret.Name = key.Name
+						secret.Namespace = key.Namespace
+						secret.Data = data
+						return nil
+					}),
+			)
+
+			secret, err := GetSecret(ctx, c, namespace, name)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(secret.Name).To(Equal("managedresource-" + name))
+			Expect(secret.Data).To(Equal(data))
+		})
+	})
+
 	Describe("#DeleteForShoot", func() {
 		var (
 			secret          = &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "managedresource-" + name}}
@@ -0,0 +1,114 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shoot
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gardener/gardener/pkg/operation/botanist"
+	"github.com/gardener/gardener/test/e2e/shoot/internal/autoscaling"
+	"github.com/gardener/gardener/test/e2e/shoot/internal/kapiloader"
+	"github.com/gardener/gardener/test/framework"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	// moderateKapiLoadRps is a request rate which makes the kapi exceed its idle CPU level, but is still comfortably
+	// served by a single (vertically inflated) replica.
+	moderateKapiLoadRps = 40
+	// heavyKapiLoadRps is a request rate which exceeds what a single kapi replica is allowed to consume, forcing the
+	// HPA to add a replica.
+	heavyKapiLoadRps = 400
+
+	// kapiTargetReplicaCount is the replica count the heavy load profile is expected to drive the kapi to.
+	kapiTargetReplicaCount = 2
+	// kapiMaxAllowedReplicaCount is the replica ceiling which the heavy load profile must not push the kapi beyond.
+	// It equals the upper bound of the autoscaler's configured scaling range - scaling to any count within that
+	// range is a legitimate response to the load, not a test failure.
+	kapiMaxAllowedReplicaCount = botanist.CustomMetricsHPAMaxReplicaCount
+)
+
+var _ = Describe("Shoot Tests", Label("Shoot"), func() {
+	f := framework.NewShootCreationFramework(&framework.ShootCreationConfig{
+		GardenerConfig: &framework.GardenerConfig{
+			ProjectNamespace:   projectNamespace,
+			GardenerKubeconfig: os.Getenv("KUBECONFIG"),
+			CommonConfig: &framework.CommonConfig{
+				ResourceDir: filepath.Join("..", "..", "framework", "resources"),
+			},
+		},
+	})
+	f.Shoot = defaultShoot("autoscale-")
+
+	It("Autoscale kube-apiserver up, out, and back in", Label("autoscaling", "slow"), func() {
+		By("Create Shoot")
+		ctx, cancel := context.WithTimeout(parentCtx, 15*time.Minute)
+		defer cancel()
+		Expect(f.CreateShootAndWaitForCreation(ctx, false)).To(Succeed())
+		f.Verify()
+		fw := f.ShootFramework
+
+		loader := kapiloader.NewKapiLoaderForShoot(fw.ShootClient, 0)
+		loader.Start(parentCtx)
+		defer func() {
+			// Verify the loader shuts down completely, so no request goroutines leak into subsequent tests
+			stopCtx, stopCancel := context.WithTimeout(context.Background(), 2*time.Minute)
+			defer stopCancel()
+			Expect(loader.StopWithContext(stopCtx)).To(Succeed())
+		}()
+
+		By("Wait for the kube-apiserver to reach its idle state")
+		ctx, cancel = context.WithTimeout(parentCtx, 40*time.Minute)
+		defer cancel()
+		idleDuration := autoscaling.WaitForIdleKapiState(ctx, fw, 40*time.Minute)
+		fw.Logger.Infof("The kube-apiserver reached its idle state after %s", idleDuration)
+
+		By("Drive moderate load and wait for the kube-apiserver to inflate vertically")
+		loader.SetTargetRps(moderateKapiLoadRps)
+		ctx, cancel = context.WithTimeout(parentCtx, 40*time.Minute)
+		defer cancel()
+		verticalInflateDuration := autoscaling.WaitForVerticallyInflatedKapiExpectSingleReplica(ctx, fw, 40*time.Minute)
+		fw.Logger.Infof("The kube-apiserver inflated vertically after %s", verticalInflateDuration)
+
+		By("Wait for the custom request rate metric to flow from gardener-custom-metrics")
+		ctx, cancel = context.WithTimeout(parentCtx, 10*time.Minute)
+		defer cancel()
+		autoscaling.WaitForCustomMetricAvailable(ctx, fw, 10*time.Minute)
+
+		By("Drive heavy load and wait for the kube-apiserver to inflate horizontally")
+		loader.SetTargetRps(heavyKapiLoadRps)
+		ctx, cancel = context.WithTimeout(parentCtx, 30*time.Minute)
+		defer cancel()
+		horizontalInflateDuration := autoscaling.WaitForHorizontallyInflatedKapi(ctx, fw, kapiTargetReplicaCount, kapiMaxAllowedReplicaCount, 30*time.Minute)
+		fw.Logger.Infof("The kube-apiserver inflated horizontally after %s", horizontalInflateDuration)
+
+		By("Remove the load and wait for the kube-apiserver to deflate")
+		loader.SetTargetRps(0)
+		ctx, cancel = context.WithTimeout(parentCtx, 60*time.Minute)
+		defer cancel()
+		deflateDuration := autoscaling.WaitForHorizontallyDeflatedKapi(ctx, fw, 60*time.Minute)
+		fw.Logger.Infof("The kube-apiserver deflated after %s", deflateDuration)
+
+		By("Delete Shoot")
+		ctx, cancel = context.WithTimeout(parentCtx, 15*time.Minute)
+		defer cancel()
+		Expect(f.DeleteShootAndWaitForDeletion(ctx, f.Shoot)).To(Succeed())
+	})
+})
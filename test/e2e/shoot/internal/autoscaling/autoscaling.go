@@ -0,0 +1,431 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package autoscaling provides helper functions for e2e tests which exercise the autoscaling of a shoot's
+// kube-apiserver ("kapi"). Each WaitFor* function fails the ginkgo test if the awaited state is not reached; the
+// underlying PollFor* functions report the outcome as a return value instead, so they can also be composed into
+// flows which react to failure (e.g. retry with a different load profile).
+package autoscaling
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	v1beta1constants "github.com/gardener/gardener/pkg/apis/core/v1beta1/constants"
+	kutil "github.com/gardener/gardener/pkg/utils/kubernetes"
+	"github.com/gardener/gardener/test/framework"
+
+	. "github.com/onsi/ginkgo/v2"
+	autoscalingv2beta1 "k8s.io/api/autoscaling/v2beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	vpaautoscalingv1 "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// pollPeriod is the period at which the PollFor* functions poll the state of the kapi.
+	pollPeriod = 15 * time.Second
+	// kapiVpaName is the name of the VPA object which holds the autoscaler's resource recommendation for the kapi.
+	kapiVpaName = v1beta1constants.DeploymentNameKubeAPIServer + "-bipa"
+	// kapiContainerName is the name of the kube-apiserver container in the kapi pods.
+	kapiContainerName = v1beta1constants.DeploymentNameKubeAPIServer
+	// kapiHpaName is the name of the HPA object which scales the kapi based on the custom request rate metric.
+	kapiHpaName = v1beta1constants.DeploymentNameKubeAPIServer + "-bipa"
+	// KapiRequestRateMetricName is the name of the custom pods metric on which the kapi's HPA scales.
+	KapiRequestRateMetricName = "shoot:apiserver_request_total:sum"
+	// deflateStabilizationPeriod is the minimum period of reduced load after which the HPA is expected to remove
+	// kapi replicas. Deflating earlier indicates a misconfigured scale-down stabilization window.
+	deflateStabilizationPeriod = 15 * time.Minute
+)
+
+// defaultKapiIdleCpuLevel is the CPU recommendation level at or below which the kapi is considered idle, unless the
+// caller specifies a different level. Shoots with an unusually high apiserver baseline (e.g. many CRDs) idle above
+// this default.
+var defaultKapiIdleCpuLevel = resource.MustParse("300m")
+
+// effectiveIdleCpuLevel returns the explicitly specified idle CPU level, if there is one, and the default otherwise.
+func effectiveIdleCpuLevel(idleCpuLevel []resource.Quantity) resource.Quantity {
+	if len(idleCpuLevel) > 0 {
+		return idleCpuLevel[0]
+	}
+	return defaultKapiIdleCpuLevel
+}
+
+// GetShootKapiPods returns the kapi pods currently running in the shoot's control plane namespace on the seed.
+func GetShootKapiPods(ctx context.Context, f *framework.ShootFramework) ([]corev1.Pod, error) {
+	podList := &corev1.PodList{}
+	if err := f.SeedClient.Client().List(ctx, podList,
+		client.InNamespace(f.ShootSeedNamespace()),
+		client.MatchingLabels{
+			v1beta1constants.LabelApp:  v1beta1constants.LabelKubernetes,
+			v1beta1constants.LabelRole: v1beta1constants.LabelAPIServer,
+		}); err != nil {
+		return nil, err
+	}
+
+	// Exclude pods which are already on their way out, e.g. remnants of a preceding rolling update
+	var pods []corev1.Pod
+	for _, pod := range podList.Items {
+		if pod.DeletionTimestamp == nil {
+			pods = append(pods, pod)
+		}
+	}
+	return pods, nil
+}
+
+// GetRecommendedResource returns the amount of the specified resource which the named VPA in the shoot's control
+// plane namespace currently recommends for the named container, or nil if there is no such recommendation (yet).
+func GetRecommendedResource(
+	ctx context.Context, f *framework.ShootFramework, vpaName, containerName string, resourceName corev1.ResourceName,
+) (*resource.Quantity, error) {
+	vpa := &vpaautoscalingv1.VerticalPodAutoscaler{}
+	if err := f.SeedClient.Client().Get(ctx, kutil.Key(f.ShootSeedNamespace(), vpaName), vpa); err != nil {
+		return nil, err
+	}
+
+	if vpa.Status.Recommendation == nil {
+		return nil, nil
+	}
+	for _, recommendation := range vpa.Status.Recommendation.ContainerRecommendations {
+		if recommendation.ContainerName == containerName {
+			if quantity, ok := recommendation.Target[resourceName]; ok {
+				return &quantity, nil
+			}
+			return nil, nil
+		}
+	}
+	return nil, nil
+}
+
+// getShootKapiRecommendedCpu returns the CPU amount currently recommended for the kapi container by the VPA, or nil
+// if there is no recommendation (yet).
+func getShootKapiRecommendedCpu(ctx context.Context, f *framework.ShootFramework) (*resource.Quantity, error) {
+	return GetRecommendedResource(ctx, f, kapiVpaName, kapiContainerName, corev1.ResourceCPU)
+}
+
+// GetKapiHpaCurrentMetricValue returns the average value which the kapi's HPA currently observes for the specified
+// custom pods metric, or nil if the HPA does not (yet) report that metric. A nil result while load is applied
+// usually means the metric is not flowing from gardener-custom-metrics at all, as opposed to flowing with a value
+// below the scale-out threshold.
+func GetKapiHpaCurrentMetricValue(ctx context.Context, f *framework.ShootFramework, metricName string) (*resource.Quantity, error) {
+	hpa := &autoscalingv2beta1.HorizontalPodAutoscaler{}
+	if err := f.SeedClient.Client().Get(ctx, kutil.Key(f.ShootSeedNamespace(), kapiHpaName), hpa); err != nil {
+		return nil, err
+	}
+
+	for _, metric := range hpa.Status.CurrentMetrics {
+		if metric.Type == autoscalingv2beta1.PodsMetricSourceType && metric.Pods != nil && metric.Pods.MetricName == metricName {
+			value := metric.Pods.CurrentAverageValue
+			return &value, nil
+		}
+	}
+	return nil, nil
+}
+
+// AutoscalingSnapshot is a point-in-time capture of the autoscaling-relevant state of the kapi, as gathered by
+// SnapshotAutoscalingState. It combines information which is otherwise scattered across the kapi pods, the HPA, and
+// the VPA, so that a single pretty-printed value provides enough context to diagnose a failed autoscaling
+// expectation.
+type AutoscalingSnapshot struct {
+	// PodCount is the number of kapi pods currently running, excluding terminating ones. A negative value means the
+	// pods could not be retrieved.
+	PodCount int
+	// RequestRateMetricValue is the average value which the HPA currently observes for the custom request rate
+	// metric, or nil if the HPA does not report that metric.
+	RequestRateMetricValue *resource.Quantity
+	// RecommendedCpu is the CPU amount currently recommended for the kapi container by the VPA, or nil if there is
+	// no recommendation.
+	RecommendedCpu *resource.Quantity
+	// Errors describes the retrieval failures encountered while the snapshot was gathered.
+	Errors []string
+}
+
+// String implements fmt.Stringer.
+func (s AutoscalingSnapshot) String() string {
+	formatQuantity := func(quantity *resource.Quantity) string {
+		if quantity == nil {
+			return "<none>"
+		}
+		return quantity.String()
+	}
+
+	result := fmt.Sprintf("pod count: %d, observed request rate: %s, recommended CPU: %s",
+		s.PodCount, formatQuantity(s.RequestRateMetricValue), formatQuantity(s.RecommendedCpu))
+	if len(s.Errors) > 0 {
+		result += ", retrieval errors: " + strings.Join(s.Errors, "; ")
+	}
+	return result
+}
+
+// SnapshotAutoscalingState gathers the current autoscaling-relevant state of the kapi - pod count, the HPA's observed
+// request rate, and the VPA's CPU recommendation - into a single AutoscalingSnapshot. Retrieval failures do not abort
+// the snapshot; the information which is available is still captured, and the failures are recorded in the snapshot
+// itself.
+func SnapshotAutoscalingState(ctx context.Context, f *framework.ShootFramework) AutoscalingSnapshot {
+	snapshot := AutoscalingSnapshot{PodCount: -1}
+
+	if pods, err := GetShootKapiPods(ctx, f); err == nil {
+		snapshot.PodCount = len(pods)
+	} else {
+		snapshot.Errors = append(snapshot.Errors, fmt.Sprintf("failed to retrieve the kapi pods: %v", err))
+	}
+
+	if value, err := GetKapiHpaCurrentMetricValue(ctx, f, KapiRequestRateMetricName); err == nil {
+		snapshot.RequestRateMetricValue = value
+	} else {
+		snapshot.Errors = append(snapshot.Errors, fmt.Sprintf("failed to retrieve the HPA's observed request rate: %v", err))
+	}
+
+	if cpu, err := getShootKapiRecommendedCpu(ctx, f); err == nil {
+		snapshot.RecommendedCpu = cpu
+	} else {
+		snapshot.Errors = append(snapshot.Errors, fmt.Sprintf("failed to retrieve the VPA's CPU recommendation: %v", err))
+	}
+
+	return snapshot
+}
+
+// failWithSnapshot fails the ginkgo test with the specified message, enriched with a snapshot of the kapi's current
+// autoscaling state.
+func failWithSnapshot(ctx context.Context, f *framework.ShootFramework, message string) {
+	Fail(fmt.Sprintf("%s\nAutoscaling state at the time of the failure: %s", message, SnapshotAutoscalingState(ctx, f)))
+}
+
+// PollForIdleKapiState waits until the kapi is in its idle state: a single replica with a CPU recommendation at or
+// below the idle level. It returns true if that state was reached within the specified timeout, and false with a
+// describing error otherwise. An explicit idle CPU level can optionally be specified as last argument; it defaults
+// to 300m.
+func PollForIdleKapiState(
+	ctx context.Context, f *framework.ShootFramework, timeout time.Duration, idleCpuLevel ...resource.Quantity,
+) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	idleLevel := effectiveIdleCpuLevel(idleCpuLevel)
+	for {
+		pods, err := GetShootKapiPods(ctx, f)
+		if err == nil && len(pods) == 1 {
+			cpu, err2 := getShootKapiRecommendedCpu(ctx, f)
+			if err2 == nil && cpu != nil && cpu.Cmp(idleLevel) <= 0 {
+				return true, nil
+			}
+			err = err2
+		}
+
+		select {
+		case <-ctx.Done():
+			return false, fmt.Errorf("the kapi did not reach idle state within %s (last error: %v)", timeout, err)
+		case <-time.After(pollPeriod):
+		}
+	}
+}
+
+// WaitForIdleKapiState is the ginkgo flavor of PollForIdleKapiState - it fails the test instead of returning an
+// error. It returns the time it took for the awaited state to be reached, so that tests can emit timing metrics.
+func WaitForIdleKapiState(ctx context.Context, f *framework.ShootFramework, timeout time.Duration, idleCpuLevel ...resource.Quantity) time.Duration {
+	started := time.Now()
+	if ok, err := PollForIdleKapiState(ctx, f, timeout, idleCpuLevel...); !ok {
+		failWithSnapshot(ctx, f, err.Error())
+	}
+	return time.Since(started)
+}
+
+// PollForVerticallyInflatedKapiSingleReplica waits until the VPA's CPU recommendation for the kapi exceeds the idle
+// level. It returns true if that happened within the specified timeout, and false with a describing error if it did
+// not, or if the kapi was scaled out in the process. An explicit idle CPU level can optionally be specified as last
+// argument; it defaults to 300m.
+func PollForVerticallyInflatedKapiSingleReplica(
+	ctx context.Context, f *framework.ShootFramework, timeout time.Duration, idleCpuLevel ...resource.Quantity,
+) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	idleLevel := effectiveIdleCpuLevel(idleCpuLevel)
+	for {
+		pods, err := GetShootKapiPods(ctx, f)
+		if err == nil && len(pods) > 1 {
+			return false, fmt.Errorf("expected the kapi to inflate vertically with a single replica, but observed %d replicas", len(pods))
+		}
+
+		cpu, err2 := getShootKapiRecommendedCpu(ctx, f)
+		if err2 == nil && cpu != nil && cpu.Cmp(idleLevel) > 0 {
+			return true, nil
+		}
+		if err == nil {
+			err = err2
+		}
+
+		select {
+		case <-ctx.Done():
+			return false, fmt.Errorf("the kapi did not inflate vertically within %s (last error: %v)", timeout, err)
+		case <-time.After(pollPeriod):
+		}
+	}
+}
+
+// WaitForVerticallyInflatedKapiExpectSingleReplica is the ginkgo flavor of PollForVerticallyInflatedKapiSingleReplica
+// - it fails the test instead of returning an error. It returns the time it took for the awaited state to be
+// reached, so that tests can emit timing metrics.
+func WaitForVerticallyInflatedKapiExpectSingleReplica(ctx context.Context, f *framework.ShootFramework, timeout time.Duration, idleCpuLevel ...resource.Quantity) time.Duration {
+	started := time.Now()
+	if ok, err := PollForVerticallyInflatedKapiSingleReplica(ctx, f, timeout, idleCpuLevel...); !ok {
+		failWithSnapshot(ctx, f, err.Error())
+	}
+	return time.Since(started)
+}
+
+// PollForCustomMetricAvailable waits until the kapi's HPA observes a numeric value for the custom request rate
+// metric provided by gardener-custom-metrics. It returns true if that happened within the specified timeout, and
+// false with a describing error otherwise. Running this before asserting scaling behavior distinguishes failures of
+// the metrics pipeline from failures of the autoscaling logic consuming it.
+func PollForCustomMetricAvailable(ctx context.Context, f *framework.ShootFramework, timeout time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		value, err := GetKapiHpaCurrentMetricValue(ctx, f, KapiRequestRateMetricName)
+		if err == nil && value != nil {
+			return true, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return false, fmt.Errorf("the kapi's HPA did not observe a value for the custom metric '%s' within %s (last error: %v)",
+				KapiRequestRateMetricName, timeout, err)
+		case <-time.After(pollPeriod):
+		}
+	}
+}
+
+// WaitForCustomMetricAvailable is the ginkgo flavor of PollForCustomMetricAvailable - it fails the test instead of
+// returning an error. It returns the time it took for the awaited state to be reached, so that tests can emit timing
+// metrics.
+func WaitForCustomMetricAvailable(ctx context.Context, f *framework.ShootFramework, timeout time.Duration) time.Duration {
+	started := time.Now()
+	if ok, err := PollForCustomMetricAvailable(ctx, f, timeout); !ok {
+		failWithSnapshot(ctx, f, err.Error())
+	}
+	return time.Since(started)
+}
+
+// ReplicaCountOption modifies the behavior of PollForKapiReplicaCount and WaitForKapiReplicaCount.
+type ReplicaCountOption func(*replicaCountOptions)
+
+type replicaCountOptions struct {
+	maxAllowedReplicaCount int
+	minStabilizationPeriod time.Duration
+}
+
+// WithMaxAllowedReplicaCount makes the poll fail immediately if the observed replica count ever exceeds the
+// specified ceiling.
+func WithMaxAllowedReplicaCount(maxAllowedReplicaCount int) ReplicaCountOption {
+	return func(o *replicaCountOptions) { o.maxAllowedReplicaCount = maxAllowedReplicaCount }
+}
+
+// WithMinStabilizationPeriod makes the poll fail if the desired replica count is reached before the specified period
+// has passed since the poll started.
+func WithMinStabilizationPeriod(minStabilizationPeriod time.Duration) ReplicaCountOption {
+	return func(o *replicaCountOptions) { o.minStabilizationPeriod = minStabilizationPeriod }
+}
+
+// PollForKapiReplicaCount waits until the kapi runs exactly the desired number of replicas. It returns true if that
+// happened within the specified timeout, and false with a describing error otherwise. The behavior can be refined
+// via ReplicaCountOptions.
+func PollForKapiReplicaCount(
+	ctx context.Context, f *framework.ShootFramework, want int, timeout time.Duration, opts ...ReplicaCountOption,
+) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	options := replicaCountOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	started := time.Now()
+	for {
+		pods, err := GetShootKapiPods(ctx, f)
+		if err == nil {
+			if options.maxAllowedReplicaCount > 0 && len(pods) > options.maxAllowedReplicaCount {
+				return false, fmt.Errorf("the kapi scaled out to %d replicas, exceeding the allowed maximum of %d", len(pods), options.maxAllowedReplicaCount)
+			}
+			if len(pods) == want {
+				if elapsed := time.Since(started); elapsed < options.minStabilizationPeriod {
+					return false, fmt.Errorf("the kapi reached %d replicas after %s, before the stabilization period of %s had passed", want, elapsed, options.minStabilizationPeriod)
+				}
+				return true, nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return false, fmt.Errorf("the kapi did not reach %d replicas within %s (last error: %v)", want, timeout, err)
+		case <-time.After(pollPeriod):
+		}
+	}
+}
+
+// WaitForKapiReplicaCount is the ginkgo flavor of PollForKapiReplicaCount - it fails the test instead of returning
+// an error. It returns the time it took for the awaited state to be reached, so that tests can emit timing metrics.
+func WaitForKapiReplicaCount(ctx context.Context, f *framework.ShootFramework, want int, timeout time.Duration, opts ...ReplicaCountOption) time.Duration {
+	started := time.Now()
+	if ok, err := PollForKapiReplicaCount(ctx, f, want, timeout, opts...); !ok {
+		failWithSnapshot(ctx, f, err.Error())
+	}
+	return time.Since(started)
+}
+
+// PollForHorizontallyInflatedKapi waits until the kapi has scaled out to targetReplicaCount replicas. It returns
+// true if that happened within the specified timeout, and false with a describing error if it did not, or if the
+// replica count exceeded maxAllowedReplicaCount - scaling beyond that ceiling means the HPA responds to the load
+// profile more aggressively than the test expects.
+func PollForHorizontallyInflatedKapi(
+	ctx context.Context, f *framework.ShootFramework, targetReplicaCount int, maxAllowedReplicaCount int, timeout time.Duration,
+) (bool, error) {
+	return PollForKapiReplicaCount(ctx, f, targetReplicaCount, timeout, WithMaxAllowedReplicaCount(maxAllowedReplicaCount))
+}
+
+// WaitForHorizontallyInflatedKapi is the ginkgo flavor of PollForHorizontallyInflatedKapi - it fails the test
+// instead of returning an error. It returns the time it took for the awaited state to be reached, so that tests can
+// emit timing metrics.
+func WaitForHorizontallyInflatedKapi(
+	ctx context.Context, f *framework.ShootFramework, targetReplicaCount int, maxAllowedReplicaCount int, timeout time.Duration,
+) time.Duration {
+	started := time.Now()
+	if ok, err := PollForHorizontallyInflatedKapi(ctx, f, targetReplicaCount, maxAllowedReplicaCount, timeout); !ok {
+		failWithSnapshot(ctx, f, err.Error())
+	}
+	return time.Since(started)
+}
+
+// PollForHorizontallyDeflatedKapi waits until the kapi is back to a single replica. It returns true if that happened
+// within the specified timeout, and false with a describing error if it did not, or if the deflate happened before
+// the scale-down stabilization period had passed, as that would indicate a misconfigured HPA.
+func PollForHorizontallyDeflatedKapi(ctx context.Context, f *framework.ShootFramework, timeout time.Duration) (bool, error) {
+	return PollForKapiReplicaCount(ctx, f, 1, timeout, WithMinStabilizationPeriod(deflateStabilizationPeriod))
+}
+
+// WaitForHorizontallyDeflatedKapi is the ginkgo flavor of PollForHorizontallyDeflatedKapi - it fails the test
+// instead of returning an error. It returns the time it took for the awaited state to be reached, so that tests can
+// emit timing metrics.
+func WaitForHorizontallyDeflatedKapi(ctx context.Context, f *framework.ShootFramework, timeout time.Duration) time.Duration {
+	started := time.Now()
+	if ok, err := PollForHorizontallyDeflatedKapi(ctx, f, timeout); !ok {
+		failWithSnapshot(ctx, f, err.Error())
+	}
+	return time.Since(started)
+}
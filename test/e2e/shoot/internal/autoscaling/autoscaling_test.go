@@ -0,0 +1,286 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package autoscaling_test
+
+import (
+	"context"
+	"time"
+
+	gardencorev1beta1 "github.com/gardener/gardener/pkg/apis/core/v1beta1"
+	"github.com/gardener/gardener/pkg/client/kubernetes"
+	fakekubernetes "github.com/gardener/gardener/pkg/client/kubernetes/fake"
+	"github.com/gardener/gardener/test/e2e/shoot/internal/autoscaling"
+	"github.com/gardener/gardener/test/framework"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	autoscalingv2beta1 "k8s.io/api/autoscaling/v2beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	vpaautoscalingv1 "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const seedNamespace = "shoot--test--autoscaling"
+
+// newFakeShootFramework returns a ShootFramework whose seed client serves the specified objects from memory.
+func newFakeShootFramework(objects ...client.Object) *framework.ShootFramework {
+	c := fakeclient.NewClientBuilder().WithScheme(kubernetes.SeedScheme).WithObjects(objects...).Build()
+	return &framework.ShootFramework{
+		SeedClient: fakekubernetes.NewClientSetBuilder().WithClient(c).Build(),
+		Shoot:      &gardencorev1beta1.Shoot{Status: gardencorev1beta1.ShootStatus{TechnicalID: seedNamespace}},
+	}
+}
+
+// newKapiPod returns a running pod carrying the labels by which the helpers identify kapi pods.
+func newKapiPod(name string) *corev1.Pod {
+	return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:      name,
+		Namespace: seedNamespace,
+		Labels:    map[string]string{"app": "kubernetes", "role": "apiserver"},
+	}}
+}
+
+var _ = Describe("autoscaling helpers", func() {
+	Describe("#GetRecommendedResource", func() {
+		var vpa *vpaautoscalingv1.VerticalPodAutoscaler
+
+		BeforeEach(func() {
+			vpa = &vpaautoscalingv1.VerticalPodAutoscaler{
+				ObjectMeta: metav1.ObjectMeta{Name: "kube-apiserver-bipa", Namespace: seedNamespace},
+				Status: vpaautoscalingv1.VerticalPodAutoscalerStatus{
+					Recommendation: &vpaautoscalingv1.RecommendedPodResources{
+						ContainerRecommendations: []vpaautoscalingv1.RecommendedContainerResources{{
+							ContainerName: "kube-apiserver",
+							Target: corev1.ResourceList{
+								corev1.ResourceCPU:    resource.MustParse("600m"),
+								corev1.ResourceMemory: resource.MustParse("1Gi"),
+							},
+						}},
+					},
+				},
+			}
+		})
+
+		It("should return the recommended CPU amount", func() {
+			f := newFakeShootFramework(vpa)
+
+			cpu, err := autoscaling.GetRecommendedResource(context.Background(), f, "kube-apiserver-bipa", "kube-apiserver", corev1.ResourceCPU)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cpu).NotTo(BeNil())
+			Expect(cpu.String()).To(Equal("600m"))
+		})
+
+		It("should return the recommended memory amount", func() {
+			f := newFakeShootFramework(vpa)
+
+			memory, err := autoscaling.GetRecommendedResource(context.Background(), f, "kube-apiserver-bipa", "kube-apiserver", corev1.ResourceMemory)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(memory).NotTo(BeNil())
+			Expect(memory.String()).To(Equal("1Gi"))
+		})
+
+		It("should return nil if the VPA has no recommendation yet", func() {
+			vpa.Status.Recommendation = nil
+			f := newFakeShootFramework(vpa)
+
+			cpu, err := autoscaling.GetRecommendedResource(context.Background(), f, "kube-apiserver-bipa", "kube-apiserver", corev1.ResourceCPU)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cpu).To(BeNil())
+		})
+
+		It("should return nil for an unknown container", func() {
+			f := newFakeShootFramework(vpa)
+
+			cpu, err := autoscaling.GetRecommendedResource(context.Background(), f, "kube-apiserver-bipa", "some-other-container", corev1.ResourceCPU)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cpu).To(BeNil())
+		})
+
+		It("should fail for a missing VPA", func() {
+			f := newFakeShootFramework()
+
+			_, err := autoscaling.GetRecommendedResource(context.Background(), f, "kube-apiserver-bipa", "kube-apiserver", corev1.ResourceCPU)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("#PollForHorizontallyInflatedKapi", func() {
+		It("should succeed for a replica count within the allowed ceiling", func() {
+			f := newFakeShootFramework(newKapiPod("kube-apiserver-1"), newKapiPod("kube-apiserver-2"), newKapiPod("kube-apiserver-3"))
+
+			ok, err := autoscaling.PollForHorizontallyInflatedKapi(context.Background(), f, 3, 4, time.Minute)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+		})
+
+		It("should fail immediately if the replica count exceeds the allowed ceiling", func() {
+			f := newFakeShootFramework(newKapiPod("kube-apiserver-1"), newKapiPod("kube-apiserver-2"), newKapiPod("kube-apiserver-3"))
+
+			ok, err := autoscaling.PollForHorizontallyInflatedKapi(context.Background(), f, 2, 2, time.Minute)
+			Expect(ok).To(BeFalse())
+			Expect(err).To(MatchError(ContainSubstring("exceeding the allowed maximum of 2")))
+		})
+	})
+
+	Describe("#PollForCustomMetricAvailable", func() {
+		newHpa := func(withMetric bool) *autoscalingv2beta1.HorizontalPodAutoscaler {
+			hpa := &autoscalingv2beta1.HorizontalPodAutoscaler{
+				ObjectMeta: metav1.ObjectMeta{Name: "kube-apiserver-bipa", Namespace: seedNamespace},
+			}
+			if withMetric {
+				hpa.Status.CurrentMetrics = []autoscalingv2beta1.MetricStatus{{
+					Type: autoscalingv2beta1.PodsMetricSourceType,
+					Pods: &autoscalingv2beta1.PodsMetricStatus{
+						MetricName:          autoscaling.KapiRequestRateMetricName,
+						CurrentAverageValue: resource.MustParse("250"),
+					},
+				}}
+			}
+			return hpa
+		}
+
+		It("should succeed once the HPA observes a value for the custom metric", func() {
+			f := newFakeShootFramework(newHpa(true))
+
+			ok, err := autoscaling.PollForCustomMetricAvailable(context.Background(), f, time.Minute)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+		})
+
+		It("should time out while the HPA observes no value for the custom metric", func() {
+			f := newFakeShootFramework(newHpa(false))
+
+			ok, err := autoscaling.PollForCustomMetricAvailable(context.Background(), f, 50*time.Millisecond)
+			Expect(ok).To(BeFalse())
+			Expect(err).To(MatchError(ContainSubstring(autoscaling.KapiRequestRateMetricName)))
+		})
+	})
+
+	Describe("#WaitForKapiReplicaCount", func() {
+		It("should report the time it took for the awaited state to be reached", func() {
+			f := newFakeShootFramework(newKapiPod("kube-apiserver-1"))
+
+			elapsed := autoscaling.WaitForKapiReplicaCount(context.Background(), f, 1, time.Minute)
+			Expect(elapsed).To(BeNumerically(">", 0))
+			Expect(elapsed).To(BeNumerically("<", time.Minute))
+		})
+	})
+
+	Describe("#SnapshotAutoscalingState", func() {
+
+		It("should combine pod count, HPA metric value, and VPA recommendation", func() {
+			hpa := &autoscalingv2beta1.HorizontalPodAutoscaler{
+				ObjectMeta: metav1.ObjectMeta{Name: "kube-apiserver-bipa", Namespace: seedNamespace},
+				Status: autoscalingv2beta1.HorizontalPodAutoscalerStatus{
+					CurrentMetrics: []autoscalingv2beta1.MetricStatus{{
+						Type: autoscalingv2beta1.PodsMetricSourceType,
+						Pods: &autoscalingv2beta1.PodsMetricStatus{
+							MetricName:          autoscaling.KapiRequestRateMetricName,
+							CurrentAverageValue: resource.MustParse("250"),
+						},
+					}},
+				},
+			}
+			vpa := &vpaautoscalingv1.VerticalPodAutoscaler{
+				ObjectMeta: metav1.ObjectMeta{Name: "kube-apiserver-bipa", Namespace: seedNamespace},
+				Status: vpaautoscalingv1.VerticalPodAutoscalerStatus{
+					Recommendation: &vpaautoscalingv1.RecommendedPodResources{
+						ContainerRecommendations: []vpaautoscalingv1.RecommendedContainerResources{{
+							ContainerName: "kube-apiserver",
+							Target:        corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("600m")},
+						}},
+					},
+				},
+			}
+			f := newFakeShootFramework(hpa, vpa, newKapiPod("kube-apiserver-1"), newKapiPod("kube-apiserver-2"))
+
+			snapshot := autoscaling.SnapshotAutoscalingState(context.Background(), f)
+
+			Expect(snapshot.Errors).To(BeEmpty())
+			Expect(snapshot.PodCount).To(Equal(2))
+			Expect(snapshot.RequestRateMetricValue).NotTo(BeNil())
+			Expect(snapshot.RequestRateMetricValue.String()).To(Equal("250"))
+			Expect(snapshot.RecommendedCpu).NotTo(BeNil())
+			Expect(snapshot.RecommendedCpu.String()).To(Equal("600m"))
+			Expect(snapshot.String()).To(And(
+				ContainSubstring("pod count: 2"),
+				ContainSubstring("observed request rate: 250"),
+				ContainSubstring("recommended CPU: 600m"),
+			))
+		})
+
+		It("should record retrieval failures instead of aborting", func() {
+			f := newFakeShootFramework(newKapiPod("kube-apiserver-1"))
+
+			snapshot := autoscaling.SnapshotAutoscalingState(context.Background(), f)
+
+			Expect(snapshot.PodCount).To(Equal(1))
+			Expect(snapshot.RequestRateMetricValue).To(BeNil())
+			Expect(snapshot.RecommendedCpu).To(BeNil())
+			Expect(snapshot.Errors).To(ContainElements(
+				ContainSubstring("request rate"),
+				ContainSubstring("CPU recommendation"),
+			))
+			Expect(snapshot.String()).To(ContainSubstring("retrieval errors"))
+		})
+	})
+
+	Describe("#GetKapiHpaCurrentMetricValue", func() {
+		var hpa *autoscalingv2beta1.HorizontalPodAutoscaler
+
+		BeforeEach(func() {
+			hpa = &autoscalingv2beta1.HorizontalPodAutoscaler{
+				ObjectMeta: metav1.ObjectMeta{Name: "kube-apiserver-bipa", Namespace: seedNamespace},
+				Status: autoscalingv2beta1.HorizontalPodAutoscalerStatus{
+					CurrentMetrics: []autoscalingv2beta1.MetricStatus{{
+						Type: autoscalingv2beta1.PodsMetricSourceType,
+						Pods: &autoscalingv2beta1.PodsMetricStatus{
+							MetricName:          autoscaling.KapiRequestRateMetricName,
+							CurrentAverageValue: resource.MustParse("250"),
+						},
+					}},
+				},
+			}
+		})
+
+		It("should return the observed average value of the custom pods metric", func() {
+			f := newFakeShootFramework(hpa)
+
+			value, err := autoscaling.GetKapiHpaCurrentMetricValue(context.Background(), f, autoscaling.KapiRequestRateMetricName)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(value).NotTo(BeNil())
+			Expect(value.String()).To(Equal("250"))
+		})
+
+		It("should return nil if the HPA does not report the metric", func() {
+			hpa.Status.CurrentMetrics = nil
+			f := newFakeShootFramework(hpa)
+
+			value, err := autoscaling.GetKapiHpaCurrentMetricValue(context.Background(), f, autoscaling.KapiRequestRateMetricName)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(value).To(BeNil())
+		})
+
+		It("should fail for a missing HPA", func() {
+			f := newFakeShootFramework()
+
+			_, err := autoscaling.GetKapiHpaCurrentMetricValue(context.Background(), f, autoscaling.KapiRequestRateMetricName)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,274 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kapiloader generates artificial request load against a shoot cluster's kube-apiserver. It is used by the
+// autoscaling e2e tests to drive the kube-apiserver out of its idle state in a controlled fashion.
+package kapiloader
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gardener/gardener/pkg/client/kubernetes"
+)
+
+const (
+	// tickPeriod is the period at which the loader evaluates how many requests are due.
+	tickPeriod = 10 * time.Millisecond
+	// maxRequestsPerTick limits how many new requests a single tick may spawn, so a delayed tick does not result in
+	// a thundering herd of goroutines.
+	maxRequestsPerTick = 100
+	// defaultMaxInFlight is the default cap on concurrently outstanding requests. Without such a cap, long high-RPS
+	// runs against a slow kube-apiserver accumulate goroutines (and file descriptors) without bound.
+	defaultMaxInFlight = 500
+)
+
+// RequestFunc issues a single request against the load target. The error, if any, is only used for bookkeeping -
+// the loader does not abort on failed requests.
+type RequestFunc func(ctx context.Context) error
+
+// KapiLoader continuously sends requests to a shoot's kube-apiserver at a specified rate. The zero rate is valid and
+// means no load. Use SetTargetRps to change the rate at any time while the loader is running.
+type KapiLoader struct {
+	lock        sync.Mutex
+	requestFunc RequestFunc
+	targetRps   int
+	maxInFlight int
+
+	// inFlight is a semaphore channel limiting the number of concurrently outstanding requests to maxInFlight.
+	inFlight chan struct{}
+
+	// The parameters of the currently active linear rate ramp, if any - see RampTargetRps
+	isRampActive  bool
+	rampStartRps  int
+	rampTargetRps int
+	rampStart     time.Time
+	rampDuration  time.Duration
+
+	// requestsAttempted counts all requests which were due according to the target rate, including dropped ones.
+	requestsAttempted int64
+	// requestsDropped counts requests which were due but could not be sent because the in-flight cap was reached.
+	requestsDropped int64
+	// statsSince marks the beginning of the measurement period for the achieved-RPS metric.
+	statsSince time.Time
+
+	cancel func()
+	wg     sync.WaitGroup
+}
+
+// NewKapiLoader creates a KapiLoader which sends requests via the specified RequestFunc. The maxInFlight parameter
+// caps the number of concurrently outstanding requests; zero or negative values mean the default of 500.
+func NewKapiLoader(requestFunc RequestFunc, maxInFlight int) *KapiLoader {
+	if maxInFlight <= 0 {
+		maxInFlight = defaultMaxInFlight
+	}
+
+	return &KapiLoader{
+		requestFunc: requestFunc,
+		maxInFlight: maxInFlight,
+		inFlight:    make(chan struct{}, maxInFlight),
+	}
+}
+
+// NewKapiLoaderForShoot creates a KapiLoader which sends trivial read requests to the kube-apiserver behind the
+// specified shoot client. Use SetRequestFunc to target a different endpoint.
+func NewKapiLoaderForShoot(shootClient kubernetes.Interface, maxInFlight int) *KapiLoader {
+	return NewKapiLoader(func(ctx context.Context) error {
+		return shootClient.RESTClient().Get().AbsPath("/version").Do(ctx).Error()
+	}, maxInFlight)
+}
+
+// SetRequestFunc replaces the function through which the loader issues its requests. It allows a running loader to be
+// pointed at a different target, e.g. an endpoint which is more expensive to serve and thus stresses the
+// kube-apiserver differently. Requests already outstanding at the time of the call still complete against the old
+// target.
+func (l *KapiLoader) SetRequestFunc(requestFunc RequestFunc) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.requestFunc = requestFunc
+}
+
+// Start begins sending requests at the currently configured target rate. It returns immediately; the load is driven
+// by a background goroutine until Stop is called or the specified context is cancelled.
+func (l *KapiLoader) Start(ctx context.Context) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.cancel != nil {
+		return
+	}
+
+	ctx, l.cancel = context.WithCancel(ctx)
+	l.statsSince = time.Now()
+	l.wg.Add(1)
+	go l.loaderProc(ctx)
+}
+
+// Stop terminates the load and waits for all outstanding requests to complete.
+func (l *KapiLoader) Stop() {
+	_ = l.StopWithContext(context.Background())
+}
+
+// StopWithContext is like Stop, but gives up waiting for completion when the specified context expires - e.g. when
+// outstanding requests hang against an unresponsive kube-apiserver. It returns nil if the loader shut down
+// completely, and the context's error otherwise. Test cleanup paths use it to verify that no loader goroutines leak
+// into subsequent test runs. The target rate is zeroed before the shutdown, so no further requests become due while
+// it propagates.
+func (l *KapiLoader) StopWithContext(ctx context.Context) error {
+	l.lock.Lock()
+	l.targetRps = 0
+	l.isRampActive = false
+	cancel := l.cancel
+	l.cancel = nil
+	l.lock.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		l.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SetTargetRps sets the rate, in requests per second, at which the loader strives to send requests. The rate changes
+// as a single step; an ongoing ramp (see RampTargetRps) is cancelled.
+func (l *KapiLoader) SetTargetRps(rps int) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.targetRps = rps
+	l.isRampActive = false
+}
+
+// RampTargetRps linearly increases the target rate from its current value to the specified target, over the specified
+// duration. Unlike the instant step performed by SetTargetRps, this mirrors how real traffic builds up, and avoids
+// confusing the stabilization logic of autoscalers observing the load. The adjustment is driven by the loader's
+// background goroutine; the call itself returns immediately. A non-positive duration is equivalent to SetTargetRps.
+func (l *KapiLoader) RampTargetRps(targetRps int, over time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if over <= 0 {
+		l.targetRps = targetRps
+		l.isRampActive = false
+		return
+	}
+
+	l.isRampActive = true
+	l.rampStartRps = l.targetRps
+	l.rampTargetRps = targetRps
+	l.rampStart = time.Now()
+	l.rampDuration = over
+}
+
+// currentRampRps returns the momentary target rate prescribed by the active ramp, and deactivates the ramp once its
+// duration has elapsed. Must be called with the lock held.
+func (l *KapiLoader) currentRampRps(now time.Time) int {
+	elapsed := now.Sub(l.rampStart)
+	if elapsed >= l.rampDuration {
+		l.isRampActive = false
+		return l.rampTargetRps
+	}
+
+	fraction := elapsed.Seconds() / l.rampDuration.Seconds()
+	return l.rampStartRps + int(float64(l.rampTargetRps-l.rampStartRps)*fraction)
+}
+
+// AchievedRps returns the rate of requests actually sent since stats were last reset, in requests per second.
+// Requests dropped because of the in-flight cap reduce this value below the target rate.
+func (l *KapiLoader) AchievedRps() float64 {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	elapsed := time.Since(l.statsSince).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(l.requestsAttempted-l.requestsDropped) / elapsed
+}
+
+// ResetStats restarts the measurement period underlying the achieved-RPS metric.
+func (l *KapiLoader) ResetStats() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.requestsAttempted = 0
+	l.requestsDropped = 0
+	l.statsSince = time.Now()
+}
+
+// loaderProc runs on a background goroutine and spawns request goroutines so that the target rate is approximated.
+// The number of concurrently outstanding requests never exceeds the configured in-flight cap - requests which are due
+// while the cap is exhausted are dropped (and recorded as such).
+func (l *KapiLoader) loaderProc(ctx context.Context) {
+	defer l.wg.Done()
+
+	ticker := time.NewTicker(tickPeriod)
+	defer ticker.Stop()
+
+	// requestDebt accumulates the fractional number of requests owed according to the target rate
+	requestDebt := 0.0
+	lastTick := time.Now()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			l.lock.Lock()
+			if l.isRampActive {
+				l.targetRps = l.currentRampRps(now)
+			}
+			requestDebt += float64(l.targetRps) * now.Sub(lastTick).Seconds()
+			lastTick = now
+
+			due := int(requestDebt)
+			if due > maxRequestsPerTick {
+				due = maxRequestsPerTick
+			}
+			requestDebt -= float64(due)
+			l.requestsAttempted += int64(due)
+
+			// Capture the request function while the lock is held - it can be replaced via SetRequestFunc
+			requestFunc := l.requestFunc
+
+			dropped := 0
+			for i := 0; i < due; i++ {
+				select {
+				case l.inFlight <- struct{}{}:
+					l.wg.Add(1)
+					go func() {
+						defer l.wg.Done()
+						defer func() { <-l.inFlight }()
+						_ = requestFunc(ctx)
+					}()
+				default:
+					dropped++
+				}
+			}
+			l.requestsDropped += int64(dropped)
+			l.lock.Unlock()
+		}
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright (c) 2022 SAP SE or an SAP affiliate company. All rights reserved. This file is licensed under the Apache Software License, v. 2 except as noted otherwise in the LICENSE file
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kapiloader_test
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	. "github.com/gardener/gardener/test/e2e/shoot/internal/kapiloader"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("KapiLoader", func() {
+	It("should never exceed the configured in-flight cap", func() {
+		const maxInFlight = 10
+
+		var current, max int64
+		requestFunc := func(ctx context.Context) error {
+			inFlight := atomic.AddInt64(&current, 1)
+			defer atomic.AddInt64(&current, -1)
+
+			// Record the high-water mark of concurrently executing requests
+			for {
+				observedMax := atomic.LoadInt64(&max)
+				if inFlight <= observedMax || atomic.CompareAndSwapInt64(&max, observedMax, inFlight) {
+					break
+				}
+			}
+
+			time.Sleep(20 * time.Millisecond)
+			return nil
+		}
+
+		loader := NewKapiLoader(requestFunc, maxInFlight)
+		loader.SetTargetRps(5000)
+		loader.Start(context.Background())
+		time.Sleep(500 * time.Millisecond)
+		loader.Stop()
+
+		Expect(atomic.LoadInt64(&max)).To(BeNumerically(">", 0))
+		Expect(atomic.LoadInt64(&max)).To(BeNumerically("<=", maxInFlight))
+		Expect(loader.AchievedRps()).To(BeNumerically(">", 0))
+	})
+
+	It("should drop requests above the cap instead of queueing them", func() {
+		block := make(chan struct{})
+		requestFunc := func(ctx context.Context) error {
+			select {
+			case <-block:
+			case <-ctx.Done():
+			}
+			return nil
+		}
+
+		loader := NewKapiLoader(requestFunc, 1)
+		loader.SetTargetRps(1000)
+		loader.Start(context.Background())
+		time.Sleep(300 * time.Millisecond)
+
+		// With all in-flight slots blocked, nearly all due requests must have been dropped
+		Expect(loader.AchievedRps()).To(BeNumerically("<", 100))
+
+		close(block)
+		loader.Stop()
+	})
+
+	It("should stop promptly after the load is removed", func() {
+		loader := NewKapiLoader(func(ctx context.Context) error { return nil }, 0)
+		loader.SetTargetRps(1000)
+		loader.Start(context.Background())
+		time.Sleep(100 * time.Millisecond)
+
+		loader.SetTargetRps(0)
+
+		stopCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		Expect(loader.StopWithContext(stopCtx)).To(Succeed())
+	})
+
+	It("should zero the rate and wait out outstanding requests when stopped under load", func() {
+		var inFlight int64
+		loader := NewKapiLoader(func(ctx context.Context) error {
+			atomic.AddInt64(&inFlight, 1)
+			defer atomic.AddInt64(&inFlight, -1)
+			time.Sleep(20 * time.Millisecond)
+			return nil
+		}, 0)
+		loader.SetTargetRps(1000)
+		loader.Start(context.Background())
+		time.Sleep(100 * time.Millisecond)
+
+		loader.Stop()
+
+		// Once Stop has returned, no request goroutines remain, and the zeroed rate spawns no new ones
+		Expect(atomic.LoadInt64(&inFlight)).To(BeZero())
+		Expect(loader.AchievedRps()).To(BeNumerically(">", 0))
+	})
+
+	It("should give up waiting for hanging requests when the stop context expires", func() {
+		block := make(chan struct{})
+		loader := NewKapiLoader(func(ctx context.Context) error {
+			<-block // Deliberately ignores ctx, simulating a hanging request
+			return nil
+		}, 1)
+		loader.SetTargetRps(1000)
+		loader.Start(context.Background())
+		time.Sleep(100 * time.Millisecond)
+
+		stopCtx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		Expect(loader.StopWithContext(stopCtx)).To(MatchError(context.DeadlineExceeded))
+
+		close(block)
+		loader.Stop()
+	})
+
+	It("should direct subsequent requests at a newly set request target", func() {
+		var oldTargetRequests, newTargetRequests int64
+		loader := NewKapiLoader(func(ctx context.Context) error {
+			atomic.AddInt64(&oldTargetRequests, 1)
+			return nil
+		}, 0)
+		loader.SetTargetRps(1000)
+		loader.Start(context.Background())
+		defer loader.Stop()
+
+		Eventually(func() int64 { return atomic.LoadInt64(&oldTargetRequests) }).
+			Should(BeNumerically(">", 0))
+
+		loader.SetRequestFunc(func(ctx context.Context) error {
+			atomic.AddInt64(&newTargetRequests, 1)
+			return nil
+		})
+
+		Eventually(func() int64 { return atomic.LoadInt64(&newTargetRequests) }).
+			Should(BeNumerically(">", 0))
+	})
+
+	It("should gradually approach the target rate while ramping", func() {
+		requestFunc := func(ctx context.Context) error { return nil }
+
+		loader := NewKapiLoader(requestFunc, 0)
+		loader.Start(context.Background())
+		defer loader.Stop()
+
+		loader.RampTargetRps(2000, 900*time.Millisecond)
+
+		// Measure the achieved rate over three consecutive windows of the ramp period
+		var windowRates []float64
+		for i := 0; i < 3; i++ {
+			loader.ResetStats()
+			time.Sleep(300 * time.Millisecond)
+			windowRates = append(windowRates, loader.AchievedRps())
+		}
+
+		// Each window must observe a higher rate than the previous one, monotonically approaching the target
+		Expect(windowRates[0]).To(BeNumerically(">", 0))
+		Expect(windowRates[1]).To(BeNumerically(">", windowRates[0]))
+		Expect(windowRates[2]).To(BeNumerically(">", windowRates[1]))
+		Expect(windowRates[2]).To(BeNumerically("<=", 2100))
+	})
+})